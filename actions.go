@@ -0,0 +1,94 @@
+package gendesk
+
+import (
+	"errors"
+	"strings"
+)
+
+// A [Desktop Action id] group, for launcher actions like "New Window"
+type Action struct {
+	ID   string
+	Name string
+	Exec string
+	Icon string // optional
+}
+
+// Parse an action given as "id:Name:Exec" or "id:Name:Exec:Icon".
+// A literal ":" in one of the fields can be written as "\:".
+func parseAction(s string) (Action, error) {
+	var (
+		fields  []string
+		current []byte
+	)
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && i+1 < len(s) && s[i+1] == ':':
+			current = append(current, ':')
+			i++
+		case s[i] == ':':
+			fields = append(fields, string(current))
+			current = nil
+		default:
+			current = append(current, s[i])
+		}
+	}
+	fields = append(fields, string(current))
+	if len(fields) < 3 || len(fields) > 4 {
+		return Action{}, errors.New("the action " + s + " should be given as id:Name:Exec or id:Name:Exec:Icon")
+	}
+	action := Action{ID: strings.TrimSpace(fields[0]), Name: strings.TrimSpace(fields[1]), Exec: strings.TrimSpace(fields[2])}
+	if len(fields) == 4 {
+		action.Icon = strings.TrimSpace(fields[3])
+	}
+	if !actionRegexp.MatchString(action.ID) {
+		return Action{}, errors.New("invalid action identifier: " + action.ID + " (only A-Z, a-z, 0-9 and - are allowed)")
+	}
+	if action.Name == "" || action.Exec == "" {
+		return Action{}, errors.New("the action " + action.ID + " needs both a name and a command")
+	}
+	return action, nil
+}
+
+// Parse a list of actions, checking that the identifiers are unique
+func ParseActions(list []string) ([]Action, error) {
+	var actions []Action
+	seen := make(map[string]bool)
+	for _, s := range list {
+		if strings.TrimSpace(s) == "" {
+			continue
+		}
+		action, err := parseAction(s)
+		if err != nil {
+			return nil, err
+		}
+		if seen[action.ID] {
+			return nil, errors.New("the action " + action.ID + " is given more than once")
+		}
+		seen[action.ID] = true
+		actions = append(actions, action)
+	}
+	return actions, nil
+}
+
+// Write the Actions key to the current group
+func (w *desktopWriter) writeActionsKey(actions []Action) {
+	ids := make([]string, len(actions))
+	for i, action := range actions {
+		ids[i] = action.ID
+	}
+	w.writeList("Actions", ids)
+}
+
+// Write a [Desktop Action id] group for each action. Localized names are
+// looked up with "Desktop Action id" as the key.
+func (w *desktopWriter) writeActionGroups(actions []Action, localized Translations) {
+	for _, action := range actions {
+		w.group("Desktop Action " + action.ID)
+		w.writeString("Name", action.Name)
+		w.writeLocalized("Name", Translations{"Name": localized["Desktop Action "+action.ID]})
+		w.writeExec("Exec", action.Exec)
+		if action.Icon != "" {
+			w.writeString("Icon", action.Icon)
+		}
+	}
+}
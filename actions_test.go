@@ -0,0 +1,71 @@
+package gendesk
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Actions are given as id:Name:Exec or id:Name:Exec:Icon, with \: for a literal colon
+func TestParseActions(t *testing.T) {
+	actions, err := ParseActions([]string{
+		"new-window:New Window:foo --new-window",
+		"",
+		"open:Open\\: Recent:foo --recent:document-open",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Action{
+		{ID: "new-window", Name: "New Window", Exec: "foo --new-window"},
+		{ID: "open", Name: "Open: Recent", Exec: "foo --recent", Icon: "document-open"},
+	}
+	if !reflect.DeepEqual(actions, expected) {
+		t.Errorf("got %+v, expected %+v", actions, expected)
+	}
+
+	for _, list := range [][]string{
+		{"new-window:New Window"},
+		{"a:b:c:d:e"},
+		{"new_window:New Window:foo"},
+		{"new-window::foo"},
+		{"new:New:foo", "new:Again:foo"},
+	} {
+		if _, err := ParseActions(list); err == nil {
+			t.Errorf("%q: expected an error", list)
+		}
+	}
+}
+
+// The Actions key lists the actions, which each have a [Desktop Action id] group
+// after the [Desktop Entry] group
+func TestWriteActions(t *testing.T) {
+	translations := make(Translations)
+	translations.set("Desktop Action private", "de", "Privates Fenster")
+	info := PackageInfo{
+		Pkgname:      "browser",
+		Actions:      []string{"new-window:New Window:browser --new-window", "private:Private Window:browser --private \"some url\":browser-private"},
+		Translations: translations,
+	}
+	contents := writeEntry(t, info, Options{})
+	if !strings.Contains(contents, "\nActions=new-window;private;\n") {
+		t.Errorf("the Actions key is missing:\n%s", contents)
+	}
+	expected := `
+[Desktop Action new-window]
+Name=New Window
+Exec=browser --new-window
+
+[Desktop Action private]
+Name=Private Window
+Name[de]=Privates Fenster
+Exec=browser --private "some url"
+Icon=browser-private
+`
+	if !strings.HasSuffix(contents, expected) {
+		t.Errorf("expected the action groups at the end:\n%s", contents)
+	}
+	if problems := Validate([]byte(contents)); len(problems) > 0 {
+		t.Errorf("line %d: %s", problems[0].Line, problems[0].Message)
+	}
+}
@@ -0,0 +1,1062 @@
+package gendesk
+
+import (
+	"bytes"
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// A small lexer and evaluator for the subset of bash that is found in PKGBUILD
+// files: variable assignments, arrays, function definitions, here-documents, the usual
+// $var, ${var} and ${var%suffix} style parameter expansions and $((...)) arithmetic.
+
+// Token kinds
+const (
+	tokWord = iota
+	tokSeparator
+	tokOpenParen
+	tokCloseParen
+	tokOpenBrace
+	tokCloseBrace
+)
+
+// Statement kinds
+const (
+	stmtAssign = iota
+	stmtFunction
+	stmtBlockStart
+	stmtBlockEnd
+	stmtCommand
+)
+
+type shellToken struct {
+	kind int
+	text string // raw text, quotes are kept until the word is expanded
+	line int
+}
+
+// A here-document that was started with <<DELIMITER or <<-DELIMITER
+type heredoc struct {
+	delimiter string
+	stripTabs bool // <<- removes leading tabs from the lines
+}
+
+type shellStatement struct {
+	kind   int
+	name   string   // variable or function name
+	words  []string // raw value words for assignments, raw arguments for commands
+	array  bool     // name=(...)
+	append bool     // name+=...
+	line   int
+}
+
+// Variables are stored as arrays. A scalar is an array with one element.
+type shellVars map[string][]string
+
+var assignmentPrefix = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*\+?=`)
+
+// Is the character a shell metacharacter that ends a word?
+func isShellMeta(c byte) bool {
+	return strings.IndexByte(" \t\n;&|()<>", c) != -1
+}
+
+// Is the character valid in a variable name?
+func isNameChar(c byte, first bool) bool {
+	if c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') {
+		return true
+	}
+	return !first && c >= '0' && c <= '9'
+}
+
+// Find the index of the closing character that matches the opening character
+// at s[start], skipping quoted sections and nested pairs. Returns -1 if not found.
+func matchingClose(s string, start int, open, close byte) int {
+	depth := 0
+	for i := start; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '\'':
+			if open != '"' {
+				end := strings.IndexByte(s[i+1:], '\'')
+				if end == -1 {
+					return -1
+				}
+				i += end + 1
+			}
+		case open:
+			depth++
+		case close:
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// Split shell source code into tokens. Quotes and expansions are kept intact
+// within the word tokens so that they can be evaluated later on.
+func lexShell(source string) []shellToken {
+	var (
+		tokens   []shellToken
+		heredocs []heredoc // the here-documents that start after the current line
+	)
+	line := 1
+	i := 0
+	for i < len(source) {
+		c := source[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\r':
+			i++
+		case c == '\\' && i+1 < len(source) && source[i+1] == '\n':
+			// Line continuation
+			line++
+			i += 2
+		case c == '#':
+			// Comment until the end of the line
+			for i < len(source) && source[i] != '\n' {
+				i++
+			}
+		case c == '\n' || c == ';' || c == '&' || c == '|':
+			tokens = append(tokens, shellToken{tokSeparator, string(c), line})
+			i++
+			if c == '\n' {
+				line++
+				// The bodies of the here-documents follow the line they were started on
+				for _, h := range heredocs {
+					i = skipHeredoc(source, i, h, &line)
+				}
+				heredocs = nil
+			}
+		case strings.HasPrefix(source[i:], "<<<"):
+			// A here-string, the word that follows is of no interest
+			i += 3
+		case strings.HasPrefix(source[i:], "<<"):
+			h, n := heredocStart(source[i:])
+			if h.delimiter != "" {
+				heredocs = append(heredocs, h)
+			}
+			i += n
+		case c == '(':
+			tokens = append(tokens, shellToken{tokOpenParen, "(", line})
+			i++
+		case c == ')':
+			tokens = append(tokens, shellToken{tokCloseParen, ")", line})
+			i++
+		case c == '<' || c == '>':
+			// Redirections are of no interest here
+			i++
+		default:
+			start, startLine := i, line
+			i = scanWord(source, i, &line)
+			word := source[start:i]
+			kind := tokWord
+			switch word {
+			case "{":
+				kind = tokOpenBrace
+			case "}":
+				kind = tokCloseBrace
+			}
+			tokens = append(tokens, shellToken{kind, word, startLine})
+		}
+	}
+	return tokens
+}
+
+// Parse the <<DELIMITER or <<-DELIMITER at the start of s. Returns the here-document
+// and the number of bytes that were consumed. The delimiter is empty if it is missing.
+func heredocStart(s string) (heredoc, int) {
+	var h heredoc
+	i := 2
+	if i < len(s) && s[i] == '-' {
+		h.stripTabs = true
+		i++
+	}
+	for i < len(s) && (s[i] == ' ' || s[i] == '\t') {
+		i++
+	}
+	line := 0
+	end := scanWord(s, i, &line)
+	// Quotes in the delimiter only turn off expansions in the body
+	h.delimiter = strings.Map(func(r rune) rune {
+		if r == '\'' || r == '"' || r == '\\' {
+			return -1
+		}
+		return r
+	}, s[i:end])
+	return h, end
+}
+
+// Skip the body of a here-document, starting at source[i], until the line with
+// the delimiter. Returns the index after the delimiter line.
+func skipHeredoc(source string, i int, h heredoc, line *int) int {
+	for i < len(source) {
+		end := strings.IndexByte(source[i:], '\n')
+		if end == -1 {
+			end = len(source) - i
+		}
+		text := source[i : i+end]
+		i += end + 1
+		*line++
+		if h.stripTabs {
+			text = strings.TrimLeft(text, "\t")
+		}
+		if strings.TrimSuffix(text, "\r") == h.delimiter {
+			break
+		}
+	}
+	return min(i, len(source))
+}
+
+// Scan a word starting at source[i] and return the index after the end of it
+func scanWord(source string, i int, line *int) int {
+	start := i
+	for i < len(source) {
+		c := source[i]
+		switch {
+		case c == '\\':
+			if i+1 < len(source) && source[i+1] == '\n' {
+				*line++
+			}
+			i += 2
+		case c == '\'':
+			end := strings.IndexByte(source[i+1:], '\'')
+			if end == -1 {
+				end = len(source) - i - 1
+			}
+			*line += strings.Count(source[i:i+end+1], "\n")
+			i += end + 2
+		case c == '"':
+			end := i + 1
+			for end < len(source) && source[end] != '"' {
+				if source[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			*line += strings.Count(source[i:min(end, len(source))], "\n")
+			i = end + 1
+		case c == '`':
+			end := strings.IndexByte(source[i+1:], '`')
+			if end == -1 {
+				end = len(source) - i - 1
+			}
+			i += end + 2
+		case c == '$' && i+1 < len(source) && source[i+1] == '\'':
+			// ANSI-C quoting, where \' does not end the string
+			end := i + 2
+			for end < len(source) && source[end] != '\'' {
+				if source[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			*line += strings.Count(source[i:min(end, len(source))], "\n")
+			i = end + 1
+		case c == '$' && i+1 < len(source) && (source[i+1] == '(' || source[i+1] == '{'):
+			open := source[i+1]
+			close := byte(')')
+			if open == '{' {
+				close = '}'
+			}
+			end := matchingClose(source, i+1, open, close)
+			if end == -1 {
+				end = len(source) - 1
+			}
+			*line += strings.Count(source[i:end], "\n")
+			i = end + 1
+		case c == '(' && assignmentPrefix.MatchString(source[start:i]) && strings.HasSuffix(source[start:i], "="):
+			// The start of an array assignment, let "(" be a token of its own
+			return i
+		case isShellMeta(c):
+			return i
+		default:
+			i++
+		}
+	}
+	return len(source)
+}
+
+// Group tokens into statements
+func parseShell(tokens []shellToken) []shellStatement {
+	var statements []shellStatement
+	for i := 0; i < len(tokens); {
+		tok := tokens[i]
+		switch tok.kind {
+		case tokSeparator, tokOpenParen, tokCloseParen:
+			i++
+			continue
+		case tokOpenBrace:
+			statements = append(statements, shellStatement{kind: stmtBlockStart, line: tok.line})
+			i++
+			continue
+		case tokCloseBrace:
+			statements = append(statements, shellStatement{kind: stmtBlockEnd, line: tok.line})
+			i++
+			continue
+		}
+		// A word
+		if loc := assignmentPrefix.FindStringIndex(tok.text); loc != nil {
+			st := shellStatement{kind: stmtAssign, line: tok.line}
+			st.name = strings.TrimRight(tok.text[:loc[1]], "+=")
+			st.append = strings.HasSuffix(tok.text[:loc[1]], "+=")
+			rest := tok.text[loc[1]:]
+			i++
+			if rest == "" && i < len(tokens) && tokens[i].kind == tokOpenParen {
+				// An array, collect the words until ")". Arrays may span several
+				// lines and contain comments, which the lexer has already removed.
+				st.array = true
+				for i++; i < len(tokens); i++ {
+					if tokens[i].kind == tokCloseParen {
+						i++
+						break
+					}
+					if tokens[i].kind != tokSeparator && tokens[i].kind != tokOpenParen {
+						st.words = append(st.words, tokens[i].text)
+					}
+				}
+			} else {
+				st.words = []string{rest}
+			}
+			statements = append(statements, st)
+			continue
+		}
+		// "function name" or "name()" followed by a body
+		name := tok.text
+		if name == "function" && i+1 < len(tokens) && tokens[i+1].kind == tokWord {
+			i++
+			name = tokens[i].text
+			if i+2 < len(tokens) && tokens[i+1].kind == tokOpenParen && tokens[i+2].kind == tokCloseParen {
+				i += 2
+			}
+			statements = append(statements, shellStatement{kind: stmtFunction, name: name, line: tok.line})
+			i++
+			continue
+		}
+		if i+2 < len(tokens) && tokens[i+1].kind == tokOpenParen && tokens[i+2].kind == tokCloseParen {
+			statements = append(statements, shellStatement{kind: stmtFunction, name: name, line: tok.line})
+			i += 3
+			continue
+		}
+		// A command, collect the words until the end of the statement
+		st := shellStatement{kind: stmtCommand, name: name, line: tok.line}
+		for i++; i < len(tokens) && tokens[i].kind == tokWord; i++ {
+			st.words = append(st.words, tokens[i].text)
+		}
+		statements = append(statements, st)
+	}
+	return statements
+}
+
+// Return a copy of the variables, for evaluating a function body
+func (vars shellVars) copy() shellVars {
+	vars2 := make(shellVars, len(vars))
+	for name, values := range vars {
+		vars2[name] = values
+	}
+	return vars2
+}
+
+// Return the value of a variable, the first element if it is an array
+func (vars shellVars) get(name string) string {
+	if values := vars[name]; len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// Evaluate an assignment statement
+func (vars shellVars) assign(st shellStatement) {
+	var values []string
+	if st.array {
+		for _, word := range st.words {
+			values = append(values, vars.expandFields(word)...)
+		}
+	} else if len(st.words) > 0 {
+		values = []string{vars.expand(st.words[0])}
+	}
+	switch {
+	case st.append && st.array:
+		vars[st.name] = append(vars[st.name], values...)
+	case st.append && len(vars[st.name]) > 0:
+		vars[st.name] = append([]string{vars.get(st.name) + strings.Join(values, "")}, vars[st.name][1:]...)
+	default:
+		if values == nil {
+			values = []string{}
+		}
+		vars[st.name] = values
+	}
+}
+
+// Expand a word to a single string, the way it is done for scalar assignments
+func (vars shellVars) expand(word string) string {
+	return strings.Join(vars.expandWord(word, false), " ")
+}
+
+// Expand a word to a list of fields, the way it is done for array elements.
+// Unquoted expansions are split on whitespace and "${arr[@]}" gives one field per element.
+func (vars shellVars) expandFields(word string) []string {
+	return vars.expandWord(word, true)
+}
+
+// Remove quotes and expand variables in a raw word
+func (vars shellVars) expandWord(word string, split bool) []string {
+	var (
+		fields  []string
+		current bytes.Buffer
+		started bool // if the current field should be kept even when empty
+	)
+	// Add text that was the result of an unquoted expansion
+	addSplit := func(s string) {
+		if !split {
+			current.WriteString(s)
+			return
+		}
+		parts := strings.Fields(s)
+		if len(parts) == 0 {
+			return
+		}
+		if strings.TrimLeft(s, " \t\n") != s && (started || current.Len() > 0) {
+			fields = append(fields, current.String())
+			current.Reset()
+		}
+		for i, part := range parts {
+			if i > 0 {
+				fields = append(fields, current.String())
+				current.Reset()
+			}
+			current.WriteString(part)
+		}
+		started = true
+		if strings.TrimRight(s, " \t\n") != s {
+			fields = append(fields, current.String())
+			current.Reset()
+			started = false
+		}
+	}
+	// Add the elements of a quoted "${arr[@]}" expansion
+	addElements := func(elements []string) {
+		for i, element := range elements {
+			if i > 0 {
+				fields = append(fields, current.String())
+				current.Reset()
+			}
+			current.WriteString(element)
+		}
+		started = true
+	}
+
+	for i := 0; i < len(word); {
+		c := word[i]
+		switch c {
+		case '\\':
+			if i+1 < len(word) && word[i+1] != '\n' {
+				current.WriteByte(word[i+1])
+			}
+			i += 2
+		case '\'':
+			end := strings.IndexByte(word[i+1:], '\'')
+			if end == -1 {
+				end = len(word) - i - 1
+			}
+			current.WriteString(word[i+1 : i+1+end])
+			started = true
+			i += end + 2
+		case '"':
+			started = true
+			for i++; i < len(word) && word[i] != '"'; {
+				switch {
+				case word[i] == '\\' && i+1 < len(word) && strings.IndexByte("$`\"\\\n", word[i+1]) != -1:
+					if word[i+1] != '\n' {
+						current.WriteByte(word[i+1])
+					}
+					i += 2
+				case word[i] == '$':
+					value, elements, n := vars.expandDollar(word[i:])
+					if elements != nil {
+						addElements(elements)
+					} else {
+						current.WriteString(value)
+					}
+					i += n
+				default:
+					current.WriteByte(word[i])
+					i++
+				}
+			}
+			i++
+		case '$':
+			if i+1 < len(word) && word[i+1] == '\'' {
+				value, n := ansiCQuoted(word[i:])
+				current.WriteString(value)
+				started = true
+				i += n
+				break
+			}
+			value, elements, n := vars.expandDollar(word[i:])
+			if elements != nil {
+				value = strings.Join(elements, " ")
+			}
+			addSplit(value)
+			i += n
+		default:
+			current.WriteByte(c)
+			started = true
+			i++
+		}
+	}
+	if started || current.Len() > 0 {
+		fields = append(fields, current.String())
+	}
+	return fields
+}
+
+// Decode the $'...' string at the start of s, with backslash escapes like \t, \n,
+// \x41 and \u00e6. Returns the decoded string and the number of bytes that were consumed.
+func ansiCQuoted(s string) (string, int) {
+	var buf bytes.Buffer
+	i := 2
+	for i < len(s) && s[i] != '\'' {
+		if s[i] != '\\' || i+1 >= len(s) {
+			buf.WriteByte(s[i])
+			i++
+			continue
+		}
+		c := s[i+1]
+		i += 2
+		switch c {
+		case 'a':
+			buf.WriteByte('\a')
+		case 'b':
+			buf.WriteByte('\b')
+		case 'e', 'E':
+			buf.WriteByte(0x1b)
+		case 'f':
+			buf.WriteByte('\f')
+		case 'n':
+			buf.WriteByte('\n')
+		case 'r':
+			buf.WriteByte('\r')
+		case 't':
+			buf.WriteByte('\t')
+		case 'v':
+			buf.WriteByte('\v')
+		case '\\', '\'', '"', '?':
+			buf.WriteByte(c)
+		case 'c':
+			// A control character, like \cA
+			if i < len(s) {
+				buf.WriteByte(s[i] & 0x1f)
+				i++
+			}
+		case 'x', 'u', 'U':
+			// Up to 2, 4 or 8 hexadecimal digits
+			digits := map[byte]int{'x': 2, 'u': 4, 'U': 8}[c]
+			n := 0
+			for n < digits && i+n < len(s) && strings.IndexByte("0123456789abcdefABCDEF", s[i+n]) != -1 {
+				n++
+			}
+			if n == 0 {
+				buf.WriteByte('\\')
+				buf.WriteByte(c)
+				continue
+			}
+			value, _ := strconv.ParseUint(s[i:i+n], 16, 32)
+			if c == 'x' {
+				buf.WriteByte(byte(value))
+			} else {
+				buf.WriteRune(rune(value))
+			}
+			i += n
+		case '0', '1', '2', '3', '4', '5', '6', '7':
+			// Up to 3 octal digits
+			n := 1
+			for n < 3 && i-1+n < len(s) && s[i-1+n] >= '0' && s[i-1+n] <= '7' {
+				n++
+			}
+			value, _ := strconv.ParseUint(s[i-1:i-1+n], 8, 32)
+			buf.WriteByte(byte(value))
+			i += n - 1
+		default:
+			// Unknown escapes are kept as they are
+			buf.WriteByte('\\')
+			buf.WriteByte(c)
+		}
+	}
+	return buf.String(), min(i+1, len(s))
+}
+
+// Expand the $ expression at the start of s. Returns the expanded value, or a list
+// of elements for "${arr[@]}", and the number of bytes that were consumed.
+func (vars shellVars) expandDollar(s string) (string, []string, int) {
+	if len(s) < 2 {
+		return s, nil, len(s)
+	}
+	switch {
+	case s[1] == '{':
+		end := matchingClose(s, 1, '{', '}')
+		if end == -1 {
+			return s, nil, len(s)
+		}
+		value, elements := vars.expandParameter(s[2:end])
+		return value, elements, end + 1
+	case s[1] == '(':
+		end := matchingClose(s, 1, '(', ')')
+		if end == -1 {
+			end = len(s) - 1
+		}
+		if strings.HasPrefix(s, "$((") && strings.HasSuffix(s[:end+1], "))") {
+			// Arithmetic expansion. Expressions that can not be evaluated give no value.
+			result, err := vars.arithmetic(vars.expand(s[3 : end-1]))
+			if err != nil {
+				return "", nil, end + 1
+			}
+			return strconv.FormatInt(result, 10), nil, end + 1
+		}
+		// Command substitution is not supported, keep the text as it is
+		return s[:end+1], nil, end + 1
+	case isNameChar(s[1], true):
+		n := 2
+		for n < len(s) && isNameChar(s[n], false) {
+			n++
+		}
+		return vars.get(s[1:n]), nil, n
+	case s[1] >= '0' && s[1] <= '9', strings.IndexByte("@*#?$!-", s[1]) != -1:
+		// Positional and special parameters are empty when sourcing a PKGBUILD
+		return "", nil, 2
+	}
+	return "$", nil, 1
+}
+
+// Evaluate an arithmetic expression, like the one in $((...)), with integers, variable
+// names, parentheses and the + - * / % ** operators
+func (vars shellVars) arithmetic(expr string) (int64, error) {
+	p := &arithParser{s: expr, vars: vars}
+	result, err := p.sum()
+	if err != nil {
+		return 0, err
+	}
+	if p.skipSpace(); p.pos < len(p.s) {
+		return 0, errors.New("unsupported arithmetic expression: " + expr)
+	}
+	return result, nil
+}
+
+// A recursive descent parser for arithmetic expressions
+type arithParser struct {
+	s    string
+	pos  int
+	vars shellVars
+}
+
+func (p *arithParser) skipSpace() {
+	for p.pos < len(p.s) && strings.IndexByte(" \t\n", p.s[p.pos]) != -1 {
+		p.pos++
+	}
+}
+
+// Check if the next operator is op, and skip it if it is
+func (p *arithParser) next(op string) bool {
+	p.skipSpace()
+	if !strings.HasPrefix(p.s[p.pos:], op) {
+		return false
+	}
+	// * should not match the start of **
+	if op == "*" && strings.HasPrefix(p.s[p.pos:], "**") {
+		return false
+	}
+	p.pos += len(op)
+	return true
+}
+
+// Parse a + b - c
+func (p *arithParser) sum() (int64, error) {
+	result, err := p.product()
+	for err == nil {
+		var value int64
+		switch {
+		case p.next("+"):
+			value, err = p.product()
+			result += value
+		case p.next("-"):
+			value, err = p.product()
+			result -= value
+		default:
+			return result, nil
+		}
+	}
+	return 0, err
+}
+
+// Parse a * b / c % d
+func (p *arithParser) product() (int64, error) {
+	result, err := p.power()
+	for err == nil {
+		var value int64
+		switch {
+		case p.next("*"):
+			value, err = p.power()
+			result *= value
+		case p.next("/"), p.next("%"):
+			op := p.s[p.pos-1]
+			if value, err = p.power(); err != nil {
+				break
+			}
+			if value == 0 {
+				return 0, errors.New("division by zero")
+			}
+			if op == '/' {
+				result /= value
+			} else {
+				result %= value
+			}
+		default:
+			return result, nil
+		}
+	}
+	return 0, err
+}
+
+// Parse a ** b, which is right associative
+func (p *arithParser) power() (int64, error) {
+	base, err := p.unary()
+	if err != nil || !p.next("**") {
+		return base, err
+	}
+	exponent, err := p.power()
+	if err != nil {
+		return 0, err
+	}
+	if exponent < 0 {
+		return 0, errors.New("exponent less than 0")
+	}
+	result := int64(1)
+	for ; exponent > 0; exponent-- {
+		result *= base
+	}
+	return result, nil
+}
+
+// Parse -a, +a, (a), a number or a variable name
+func (p *arithParser) unary() (int64, error) {
+	switch {
+	case p.next("-"):
+		value, err := p.unary()
+		return -value, err
+	case p.next("+"):
+		return p.unary()
+	case p.next("("):
+		value, err := p.sum()
+		if err == nil && !p.next(")") {
+			err = errors.New("missing ) in arithmetic expression")
+		}
+		return value, err
+	}
+	start := p.pos
+	for p.pos < len(p.s) && isNameChar(p.s[p.pos], false) {
+		p.pos++
+	}
+	word := p.s[start:p.pos]
+	switch {
+	case word == "":
+		return 0, errors.New("unsupported arithmetic expression: " + p.s)
+	case isNameChar(word[0], true):
+		// Variables that are unset or empty are 0
+		word = strings.TrimSpace(p.vars.get(word))
+		if word == "" {
+			return 0, nil
+		}
+	}
+	// Numbers like 010 are octal and 0x10 hexadecimal, as in bash
+	return strconv.ParseInt(word, 0, 64)
+}
+
+// Expand the contents of a ${...} expression
+func (vars shellVars) expandParameter(expr string) (string, []string) {
+	// ${#var} gives the length, ${#arr[@]} the number of elements
+	if strings.HasPrefix(expr, "#") && len(expr) > 1 {
+		name := expr[1:]
+		if strings.HasSuffix(name, "[@]") || strings.HasSuffix(name, "[*]") {
+			return strconv.Itoa(len(vars[name[:len(name)-3]])), nil
+		}
+		return strconv.Itoa(len([]rune(vars.get(name)))), nil
+	}
+
+	// Find the name and an optional [index]
+	n := 0
+	for n < len(expr) && isNameChar(expr[n], n == 0) {
+		n++
+	}
+	name := expr[:n]
+	rest := expr[n:]
+	values := vars[name]
+	all := false
+	if strings.HasPrefix(rest, "[") {
+		end := strings.IndexByte(rest, ']')
+		if end == -1 {
+			return "", nil
+		}
+		switch index := rest[1:end]; index {
+		case "@", "*":
+			all = true
+		default:
+			i, err := strconv.Atoi(vars.expand(index))
+			if err != nil || i < 0 || i >= len(values) {
+				values = nil
+			} else {
+				values = values[i : i+1]
+			}
+		}
+		rest = rest[end+1:]
+	}
+	if !all && len(values) > 1 {
+		values = values[:1]
+	}
+	_, set := vars[name]
+	set = set && values != nil
+
+	result := func(values []string) (string, []string) {
+		if all {
+			if values == nil {
+				values = []string{}
+			}
+			return "", values
+		}
+		return strings.Join(values, ""), nil
+	}
+	// Apply an operation to every element
+	each := func(f func(string) string) (string, []string) {
+		modified := make([]string, len(values))
+		for i, value := range values {
+			modified[i] = f(value)
+		}
+		return result(modified)
+	}
+
+	switch {
+	case rest == "":
+		return result(values)
+	case strings.HasPrefix(rest, ":-"), strings.HasPrefix(rest, "-"):
+		empty := !set || (strings.HasPrefix(rest, ":") && strings.Join(values, "") == "")
+		if empty {
+			return vars.expand(strings.TrimPrefix(strings.TrimPrefix(rest, ":"), "-")), nil
+		}
+		return result(values)
+	case strings.HasPrefix(rest, ":="), strings.HasPrefix(rest, "="):
+		empty := !set || (strings.HasPrefix(rest, ":") && strings.Join(values, "") == "")
+		if empty {
+			value := vars.expand(strings.TrimPrefix(strings.TrimPrefix(rest, ":"), "="))
+			vars[name] = []string{value}
+			return value, nil
+		}
+		return result(values)
+	case strings.HasPrefix(rest, ":+"), strings.HasPrefix(rest, "+"):
+		empty := !set || (strings.HasPrefix(rest, ":") && strings.Join(values, "") == "")
+		if empty {
+			return "", nil
+		}
+		return vars.expand(strings.TrimPrefix(strings.TrimPrefix(rest, ":"), "+")), nil
+	case strings.HasPrefix(rest, ":?"), strings.HasPrefix(rest, "?"):
+		return result(values)
+	case strings.HasPrefix(rest, ":"):
+		// Substring, ${var:offset} or ${var:offset:length}
+		parts := strings.SplitN(rest[1:], ":", 2)
+		offset, err := strconv.Atoi(strings.TrimSpace(vars.expand(parts[0])))
+		if err != nil {
+			return result(values)
+		}
+		length := -1
+		if len(parts) == 2 {
+			if length, err = strconv.Atoi(strings.TrimSpace(vars.expand(parts[1]))); err != nil {
+				return result(values)
+			}
+		}
+		return each(func(s string) string { return substring(s, offset, length) })
+	case strings.HasPrefix(rest, "%%"):
+		pattern := vars.expand(rest[2:])
+		return each(func(s string) string { return trimSuffixPattern(s, pattern, true) })
+	case strings.HasPrefix(rest, "%"):
+		pattern := vars.expand(rest[1:])
+		return each(func(s string) string { return trimSuffixPattern(s, pattern, false) })
+	case strings.HasPrefix(rest, "##"):
+		pattern := vars.expand(rest[2:])
+		return each(func(s string) string { return trimPrefixPattern(s, pattern, true) })
+	case strings.HasPrefix(rest, "#"):
+		pattern := vars.expand(rest[1:])
+		return each(func(s string) string { return trimPrefixPattern(s, pattern, false) })
+	case strings.HasPrefix(rest, "/"):
+		replaceAll := strings.HasPrefix(rest, "//")
+		spec := rest[1:]
+		if replaceAll {
+			spec = rest[2:]
+		}
+		pattern, replacement := spec, ""
+		if pos := strings.IndexByte(spec, '/'); pos != -1 {
+			pattern, replacement = spec[:pos], spec[pos+1:]
+		}
+		pattern = vars.expand(pattern)
+		replacement = vars.expand(replacement)
+		return each(func(s string) string { return replacePattern(s, pattern, replacement, replaceAll) })
+	case rest == "^^":
+		return each(strings.ToUpper)
+	case rest == "^":
+		return each(func(s string) string { return strings.ToUpper(s[:min(1, len(s))]) + s[min(1, len(s)):] })
+	case rest == ",,":
+		return each(strings.ToLower)
+	case rest == ",":
+		return each(func(s string) string { return strings.ToLower(s[:min(1, len(s))]) + s[min(1, len(s)):] })
+	}
+	// Unsupported expansion
+	return result(values)
+}
+
+// Return a substring the way ${var:offset:length} does
+func substring(s string, offset, length int) string {
+	runes := []rune(s)
+	if offset < 0 {
+		offset += len(runes)
+	}
+	if offset < 0 || offset > len(runes) {
+		return ""
+	}
+	runes = runes[offset:]
+	if length < 0 {
+		length += len(runes)
+		if length < 0 {
+			return ""
+		}
+	}
+	if length < len(runes) {
+		runes = runes[:length]
+	}
+	return string(runes)
+}
+
+// Convert a shell glob pattern to an anchored regular expression
+func globToRegexp(pattern string) *regexp.Regexp {
+	var re bytes.Buffer
+	re.WriteString(`^(?s:`)
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; c {
+		case '*':
+			re.WriteString(".*")
+		case '?':
+			re.WriteString(".")
+		case '\\':
+			if i+1 < len(pattern) {
+				i++
+				re.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+			}
+		case '[':
+			end := strings.IndexByte(pattern[i+1:], ']')
+			if end == -1 {
+				re.WriteString(`\[`)
+				continue
+			}
+			class := pattern[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			re.WriteString("[" + strings.Replace(class, `\`, `\\`, -1) + "]")
+			i += end + 1
+		default:
+			re.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	re.WriteString(`)$`)
+	compiled, err := regexp.Compile(re.String())
+	if err != nil {
+		return regexp.MustCompile(`^` + regexp.QuoteMeta(pattern) + `$`)
+	}
+	return compiled
+}
+
+// Remove the shortest (or longest) suffix matching the pattern, like ${var%pattern}
+func trimSuffixPattern(s, pattern string, longest bool) string {
+	re := globToRegexp(pattern)
+	if longest {
+		for i := 0; i <= len(s); i++ {
+			if re.MatchString(s[i:]) {
+				return s[:i]
+			}
+		}
+		return s
+	}
+	for i := len(s); i >= 0; i-- {
+		if re.MatchString(s[i:]) {
+			return s[:i]
+		}
+	}
+	return s
+}
+
+// Remove the shortest (or longest) prefix matching the pattern, like ${var#pattern}
+func trimPrefixPattern(s, pattern string, longest bool) string {
+	re := globToRegexp(pattern)
+	if longest {
+		for i := len(s); i >= 0; i-- {
+			if re.MatchString(s[:i]) {
+				return s[i:]
+			}
+		}
+		return s
+	}
+	for i := 0; i <= len(s); i++ {
+		if re.MatchString(s[:i]) {
+			return s[i:]
+		}
+	}
+	return s
+}
+
+// Replace the longest match of the pattern, like ${var/pattern/replacement}.
+// Patterns starting with # or % are anchored to the start or the end.
+func replacePattern(s, pattern, replacement string, all bool) string {
+	switch {
+	case pattern == "":
+		return s
+	case strings.HasPrefix(pattern, "#"):
+		re := globToRegexp(pattern[1:])
+		for i := len(s); i >= 0; i-- {
+			if re.MatchString(s[:i]) {
+				return replacement + s[i:]
+			}
+		}
+		return s
+	case strings.HasPrefix(pattern, "%"):
+		re := globToRegexp(pattern[1:])
+		for i := 0; i <= len(s); i++ {
+			if re.MatchString(s[i:]) {
+				return s[:i] + replacement
+			}
+		}
+		return s
+	}
+	re := globToRegexp(pattern)
+	var result bytes.Buffer
+	for start := 0; start < len(s); {
+		matched := false
+		for end := len(s); end > start; end-- {
+			if re.MatchString(s[start:end]) {
+				result.WriteString(replacement)
+				start = end
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			result.WriteByte(s[start])
+			start++
+			continue
+		}
+		if !all {
+			result.WriteString(s[start:])
+			return result.String()
+		}
+	}
+	return result.String()
+}
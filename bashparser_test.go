@@ -0,0 +1,110 @@
+package gendesk
+
+import (
+	"reflect"
+	"testing"
+)
+
+// Evaluate the global assignments in shell source code, the way a PKGBUILD is sourced
+func evalShell(source string) shellVars {
+	vars := make(shellVars)
+	depth := 0
+	for _, st := range parseShell(lexShell(source)) {
+		switch st.kind {
+		case stmtBlockStart:
+			depth++
+		case stmtBlockEnd:
+			depth--
+		case stmtAssign:
+			if depth == 0 {
+				vars.assign(st)
+			}
+		}
+	}
+	return vars
+}
+
+// Check that the variables have the expected values
+func checkVars(t *testing.T, name string, vars shellVars, expected map[string][]string) {
+	for variable, values := range expected {
+		if !reflect.DeepEqual(vars[variable], values) {
+			t.Errorf("%s: %s = %q, expected %q", name, variable, vars[variable], values)
+		}
+	}
+}
+
+// The constructs found in PKGBUILD files are lexed and evaluated as in bash
+func TestLexShell(t *testing.T) {
+	tests := []struct {
+		name     string
+		source   string
+		expected map[string][]string
+	}{
+		{
+			"comments",
+			"# Maintainer: Someone <someone@example.org>\npkgname=foo # the name\n_url=http://example.org/#top\n",
+			map[string][]string{"pkgname": {"foo"}, "_url": {"http://example.org/#top"}},
+		},
+		{
+			"line continuations",
+			"pkgdesc=\"A long \\\ndescription\"\n_exec=foo\\\n-bar\ndepends=(a \\\n  b)\n",
+			map[string][]string{"pkgdesc": {"A long description"}, "_exec": {"foo-bar"}, "depends": {"a", "b"}},
+		},
+		{
+			"multi-line arrays",
+			"depends=(\n  'gtk3'   # for the GUI\n  \"libfoo\" # )\n\n  bar\n)\nlicense=(MIT)\n",
+			map[string][]string{"depends": {"gtk3", "libfoo", "bar"}, "license": {"MIT"}},
+		},
+		{
+			"case and esac",
+			"case \"$CARCH\" in\n  x86_64) _arch=x64 ;;\n  i686|armv7h) _arch=x86 ;;\nesac\n_exec=foo-$_arch\n",
+			map[string][]string{"_exec": {"foo-x86"}},
+		},
+		{
+			"here-documents",
+			"package_foo() {\n  cat <<EOF > \"$pkgdir/README\"\nDon't run this as root\npkgdesc=wrong\nEOF\n  pkgdesc=\"Foo\"\n}\n_exec=foo\n",
+			map[string][]string{"_exec": {"foo"}, "pkgdesc": nil},
+		},
+		{
+			"here-documents with quoted delimiters and tabs",
+			"prepare() {\n\tcat <<-'END' >foo.sh\n\t\techo \"it's $HOME\"\n\tEND\n\tcat << \"A\"; cat <<B\n'\nA\n\"\nB\n}\n_name=Foo\n",
+			map[string][]string{"_name": {"Foo"}},
+		},
+		{
+			"here-strings",
+			"build() {\n  grep foo <<< \"it's\"\n}\n_name=Foo\n",
+			map[string][]string{"_name": {"Foo"}},
+		},
+		{
+			"arithmetic",
+			"_major=2\npkgver=$((_major * 10 + 3)).$(( (1 + 2) ** 2 % 5 ))\npkgrel=$(($_major - 3))\n_bad=$((1 / 0))\n_octal=$((010 + 0x10))\n",
+			map[string][]string{"pkgver": {"23.4"}, "pkgrel": {"-1"}, "_bad": {""}, "_octal": {"24"}},
+		},
+		{
+			"command substitution",
+			"pkgver=$(date +%Y)\n",
+			map[string][]string{"pkgver": {"$(date +%Y)"}},
+		},
+	}
+	for _, test := range tests {
+		checkVars(t, test.name, evalShell(test.source), test.expected)
+	}
+}
+
+// ANSI-C quoting, like $'foo\tbar', is expanded the way bash does it
+func TestANSICQuoting(t *testing.T) {
+	source := `pkgdesc=$'foo\tbar'
+_comment=$'it\'s a \x41æ\101 test'"!"
+_name=$'multi
+line' # comment
+depends=($'a\nb' "c")
+_quoted="not $'ansi'"
+`
+	checkVars(t, "ANSI-C quoting", evalShell(source), map[string][]string{
+		"pkgdesc":  {"foo\tbar"},
+		"_comment": {"it's a AæA test!"},
+		"_name":    {"multi\nline"},
+		"depends":  {"a\nb", "c"},
+		"_quoted":  {"not $'ansi'"},
+	})
+}
@@ -0,0 +1,418 @@
+package gendesk
+
+import (
+	"strings"
+)
+
+// The registered categories from the Desktop Menu Specification
+// https://specifications.freedesktop.org/menu-spec/latest/apa.html
+
+var (
+	mainCategories = map[string]bool{
+		"AudioVideo":  true,
+		"Audio":       true,
+		"Video":       true,
+		"Development": true,
+		"Education":   true,
+		"Game":        true,
+		"Graphics":    true,
+		"Network":     true,
+		"Office":      true,
+		"Science":     true,
+		"Settings":    true,
+		"System":      true,
+		"Utility":     true,
+	}
+	additionalCategories = map[string]bool{
+		"Building":               true,
+		"Debugger":               true,
+		"IDE":                    true,
+		"GUIDesigner":            true,
+		"Profiling":              true,
+		"RevisionControl":        true,
+		"Translation":            true,
+		"Calendar":               true,
+		"ContactManagement":      true,
+		"Database":               true,
+		"Dictionary":             true,
+		"Chart":                  true,
+		"Email":                  true,
+		"Finance":                true,
+		"FlowChart":              true,
+		"PDA":                    true,
+		"ProjectManagement":      true,
+		"Presentation":           true,
+		"Spreadsheet":            true,
+		"WordProcessor":          true,
+		"2DGraphics":             true,
+		"VectorGraphics":         true,
+		"RasterGraphics":         true,
+		"3DGraphics":             true,
+		"Scanning":               true,
+		"OCR":                    true,
+		"Photography":            true,
+		"Publishing":             true,
+		"Viewer":                 true,
+		"TextTools":              true,
+		"DesktopSettings":        true,
+		"HardwareSettings":       true,
+		"Printing":               true,
+		"PackageManager":         true,
+		"Dialup":                 true,
+		"InstantMessaging":       true,
+		"Chat":                   true,
+		"IRCClient":              true,
+		"Feed":                   true,
+		"FileTransfer":           true,
+		"HamRadio":               true,
+		"News":                   true,
+		"P2P":                    true,
+		"RemoteAccess":           true,
+		"Telephony":              true,
+		"TelephonyTools":         true,
+		"VideoConference":        true,
+		"WebBrowser":             true,
+		"WebDevelopment":         true,
+		"Midi":                   true,
+		"Mixer":                  true,
+		"Sequencer":              true,
+		"Tuner":                  true,
+		"TV":                     true,
+		"AudioVideoEditing":      true,
+		"Player":                 true,
+		"Recorder":               true,
+		"DiscBurning":            true,
+		"ActionGame":             true,
+		"AdventureGame":          true,
+		"ArcadeGame":             true,
+		"BoardGame":              true,
+		"BlocksGame":             true,
+		"CardGame":               true,
+		"KidsGame":               true,
+		"LogicGame":              true,
+		"RolePlaying":            true,
+		"Shooter":                true,
+		"Simulation":             true,
+		"SportsGame":             true,
+		"StrategyGame":           true,
+		"Art":                    true,
+		"Construction":           true,
+		"Music":                  true,
+		"Languages":              true,
+		"ArtificialIntelligence": true,
+		"Astronomy":              true,
+		"Biology":                true,
+		"Chemistry":              true,
+		"ComputerScience":        true,
+		"DataVisualization":      true,
+		"Economy":                true,
+		"Electricity":            true,
+		"Geography":              true,
+		"Geology":                true,
+		"Geoscience":             true,
+		"History":                true,
+		"Humanities":             true,
+		"ImageProcessing":        true,
+		"Literature":             true,
+		"Maps":                   true,
+		"Math":                   true,
+		"NumericalAnalysis":      true,
+		"MedicalSoftware":        true,
+		"Physics":                true,
+		"Robotics":               true,
+		"Spirituality":           true,
+		"Sports":                 true,
+		"ParallelComputing":      true,
+		"Amusement":              true,
+		"Archiving":              true,
+		"Compression":            true,
+		"Electronics":            true,
+		"Emulator":               true,
+		"Engineering":            true,
+		"FileTools":              true,
+		"FileManager":            true,
+		"TerminalEmulator":       true,
+		"Filesystem":             true,
+		"Monitor":                true,
+		"Security":               true,
+		"Accessibility":          true,
+		"Calculator":             true,
+		"Clock":                  true,
+		"TextEditor":             true,
+		"Documentation":          true,
+		"Adult":                  true,
+		"Core":                   true,
+		"KDE":                    true,
+		"GNOME":                  true,
+		"XFCE":                   true,
+		"DDE":                    true,
+		"GTK":                    true,
+		"Qt":                     true,
+		"Motif":                  true,
+		"Java":                   true,
+		"ConsoleOnly":            true,
+	}
+	reservedCategories = map[string]bool{
+		"Screensaver": true,
+		"TrayIcon":    true,
+		"Applet":      true,
+		"Shell":       true,
+	}
+	// Registered values for OnlyShowIn and NotShowIn
+	registeredEnvironments = map[string]bool{
+		"GNOME":           true,
+		"GNOME-Classic":   true,
+		"GNOME-Flashback": true,
+		"KDE":             true,
+		"LXDE":            true,
+		"LXQt":            true,
+		"MATE":            true,
+		"Razor":           true,
+		"ROX":             true,
+		"TDE":             true,
+		"Unity":           true,
+		"XFCE":            true,
+		"EDE":             true,
+		"Cinnamon":        true,
+		"Pantheon":        true,
+		"Budgie":          true,
+		"Enlightenment":   true,
+		"DDE":             true,
+		"Endless":         true,
+		"Old":             true,
+	}
+	// The categories that additional categories should be used together with, from the
+	// "Related Categories" column of the specification. At least one of the alternatives
+	// should be satisfied, by including all the categories in it. Audio and Video are
+	// main categories, but should also be used together with AudioVideo.
+	relatedCategories = map[string][][]string{
+		"Audio":                  {{"AudioVideo"}},
+		"Video":                  {{"AudioVideo"}},
+		"Building":               {{"Development"}},
+		"Debugger":               {{"Development"}},
+		"IDE":                    {{"Development"}},
+		"GUIDesigner":            {{"Development"}},
+		"Profiling":              {{"Development"}},
+		"RevisionControl":        {{"Development"}},
+		"Translation":            {{"Development"}},
+		"Calendar":               {{"Office"}},
+		"ContactManagement":      {{"Office"}},
+		"Database":               {{"Office"}, {"Development"}, {"AudioVideo"}},
+		"Dictionary":             {{"Office", "TextTools"}},
+		"Chart":                  {{"Office"}},
+		"Email":                  {{"Office", "Network"}},
+		"Finance":                {{"Office"}},
+		"FlowChart":              {{"Office"}},
+		"PDA":                    {{"Office"}},
+		"ProjectManagement":      {{"Office", "Development"}},
+		"Presentation":           {{"Office"}},
+		"Spreadsheet":            {{"Office"}},
+		"WordProcessor":          {{"Office"}},
+		"2DGraphics":             {{"Graphics"}},
+		"VectorGraphics":         {{"Graphics", "2DGraphics"}},
+		"RasterGraphics":         {{"Graphics", "2DGraphics"}},
+		"3DGraphics":             {{"Graphics"}},
+		"Scanning":               {{"Graphics"}},
+		"OCR":                    {{"Graphics", "Scanning"}},
+		"Photography":            {{"Graphics"}, {"Office"}},
+		"Publishing":             {{"Graphics"}, {"Office"}},
+		"Viewer":                 {{"Graphics"}, {"Office"}},
+		"TextTools":              {{"Utility"}},
+		"DesktopSettings":        {{"Settings"}},
+		"HardwareSettings":       {{"Settings"}},
+		"Printing":               {{"HardwareSettings", "Settings"}},
+		"PackageManager":         {{"Settings"}},
+		"Dialup":                 {{"Network"}},
+		"InstantMessaging":       {{"Network"}},
+		"Chat":                   {{"Network"}},
+		"IRCClient":              {{"Network"}},
+		"Feed":                   {{"Network"}},
+		"FileTransfer":           {{"Network"}},
+		"HamRadio":               {{"Network"}, {"Audio"}},
+		"News":                   {{"Network"}},
+		"P2P":                    {{"Network"}},
+		"RemoteAccess":           {{"Network"}},
+		"Telephony":              {{"Network"}},
+		"TelephonyTools":         {{"Utility"}},
+		"VideoConference":        {{"Network"}},
+		"WebBrowser":             {{"Network"}},
+		"WebDevelopment":         {{"Network"}, {"Development"}},
+		"Midi":                   {{"AudioVideo", "Audio"}},
+		"Mixer":                  {{"AudioVideo", "Audio"}},
+		"Sequencer":              {{"AudioVideo", "Audio"}},
+		"Tuner":                  {{"AudioVideo", "Audio"}},
+		"TV":                     {{"AudioVideo", "Video"}},
+		"AudioVideoEditing":      {{"Audio"}, {"Video"}, {"AudioVideo"}},
+		"Player":                 {{"Audio"}, {"Video"}, {"AudioVideo"}},
+		"Recorder":               {{"Audio"}, {"Video"}, {"AudioVideo"}},
+		"DiscBurning":            {{"AudioVideo"}},
+		"ActionGame":             {{"Game"}},
+		"AdventureGame":          {{"Game"}},
+		"ArcadeGame":             {{"Game"}},
+		"BoardGame":              {{"Game"}},
+		"BlocksGame":             {{"Game"}},
+		"CardGame":               {{"Game"}},
+		"KidsGame":               {{"Game"}},
+		"LogicGame":              {{"Game"}},
+		"RolePlaying":            {{"Game"}},
+		"Shooter":                {{"Game"}},
+		"Simulation":             {{"Game"}},
+		"SportsGame":             {{"Game"}},
+		"StrategyGame":           {{"Game"}},
+		"Art":                    {{"Education"}, {"Science"}},
+		"Construction":           {{"Education"}, {"Science"}},
+		"Music":                  {{"AudioVideo"}, {"Education"}},
+		"Languages":              {{"Education"}, {"Science"}},
+		"ArtificialIntelligence": {{"Education"}, {"Science"}},
+		"Astronomy":              {{"Education"}, {"Science"}},
+		"Biology":                {{"Education"}, {"Science"}},
+		"Chemistry":              {{"Education"}, {"Science"}},
+		"ComputerScience":        {{"Education"}, {"Science"}},
+		"DataVisualization":      {{"Education"}, {"Science"}},
+		"Economy":                {{"Education"}, {"Science"}},
+		"Electricity":            {{"Education"}, {"Science"}},
+		"Geography":              {{"Education"}, {"Science"}},
+		"Geology":                {{"Education"}, {"Science"}},
+		"Geoscience":             {{"Education"}, {"Science"}},
+		"History":                {{"Education"}, {"Science"}},
+		"Humanities":             {{"Education"}, {"Science"}},
+		"ImageProcessing":        {{"Education"}, {"Science"}},
+		"Literature":             {{"Education"}, {"Science"}},
+		"Maps":                   {{"Education"}, {"Science"}, {"Utility"}},
+		"Math":                   {{"Education"}, {"Science"}},
+		"NumericalAnalysis":      {{"Education", "Math"}, {"Science", "Math"}},
+		"MedicalSoftware":        {{"Education"}, {"Science"}},
+		"Physics":                {{"Education"}, {"Science"}},
+		"Robotics":               {{"Education"}, {"Science"}},
+		"Spirituality":           {{"Education"}, {"Science"}, {"Utility"}},
+		"Sports":                 {{"Education"}, {"Science"}},
+		"ParallelComputing":      {{"Education", "ComputerScience"}, {"Science", "ComputerScience"}},
+		"Archiving":              {{"Utility"}},
+		"Compression":            {{"Utility"}},
+		"Emulator":               {{"System"}, {"Game"}},
+		"FileTools":              {{"Utility"}, {"System"}},
+		"FileManager":            {{"System", "FileTools"}},
+		"TerminalEmulator":       {{"System"}},
+		"Filesystem":             {{"System"}},
+		"Monitor":                {{"System"}, {"Network"}},
+		"Security":               {{"Settings"}, {"System"}},
+		"Accessibility":          {{"Settings"}, {"Utility"}},
+		"Calculator":             {{"Utility"}},
+		"Clock":                  {{"Utility"}},
+		"TextEditor":             {{"Utility"}},
+		"KDE":                    {{"Qt"}},
+		"GNOME":                  {{"GTK"}},
+		"XFCE":                   {{"GTK"}},
+		"DDE":                    {{"Qt"}},
+	}
+)
+
+// Is the category registered, either as a main or as an additional category?
+func registeredCategory(category string) bool {
+	return mainCategories[category] || additionalCategories[category] || reservedCategories[category]
+}
+
+// Return the registered category with the same name, ignoring case, if any
+func registeredCategoryFold(category string) (string, bool) {
+	for _, table := range []map[string]bool{mainCategories, additionalCategories, reservedCategories} {
+		for registered := range table {
+			if strings.EqualFold(registered, category) {
+				return registered, true
+			}
+		}
+	}
+	return "", false
+}
+
+// Return the related categories that are missing for a category, or nil if
+// the category has no related categories or one of the alternatives is satisfied
+func missingRelatedCategories(category string, categories []string) []string {
+	alternatives := relatedCategories[category]
+	if len(alternatives) == 0 {
+		return nil
+	}
+	var first []string
+	for i, alternative := range alternatives {
+		var missing []string
+		for _, related := range alternative {
+			if !containsString(categories, related) {
+				missing = append(missing, related)
+			}
+		}
+		if len(missing) == 0 {
+			return nil
+		}
+		if i == 0 {
+			first = missing
+		}
+	}
+	return first
+}
+
+// Describe the related categories of a category, like "Office or Development"
+func describeRelatedCategories(category string) string {
+	var alternatives []string
+	for _, alternative := range relatedCategories[category] {
+		alternatives = append(alternatives, strings.Join(alternative, " and "))
+	}
+	return strings.Join(alternatives, " or ")
+}
+
+// Normalize a list of categories, both guessed and given: fix the case of registered
+// categories, remove duplicates, empty elements and "Application", add the related
+// categories that are required by additional categories and add an X- prefix to
+// categories that are not registered. Returns the categories and any warnings.
+func normalizeCategories(categories []string) ([]string, []string) {
+	var (
+		normalized []string
+		warnings   []string
+	)
+	add := func(category string) {
+		if !containsString(normalized, category) {
+			normalized = append(normalized, category)
+		}
+	}
+	for _, category := range categories {
+		category = strings.TrimSpace(category)
+		switch {
+		case category == "" || category == "Application":
+			// "Application" was used by older versions of gendesk, but is not registered
+			continue
+		case strings.HasPrefix(category, "X-") || registeredCategory(category):
+			add(category)
+		default:
+			if registered, found := registeredCategoryFold(category); found {
+				add(registered)
+				continue
+			}
+			warnings = append(warnings, "the category "+category+" is not registered, using X-"+category+" instead")
+			add("X-" + category)
+		}
+	}
+	// Related categories may be additional categories themselves, like TextTools for
+	// Dictionary, so add them until there is nothing more to add
+	for {
+		withRelated := addRelatedCategories(normalized)
+		if len(withRelated) == len(normalized) {
+			return withRelated, warnings
+		}
+		normalized = withRelated
+	}
+}
+
+// Add the missing related categories in front of the additional categories that require them
+func addRelatedCategories(categories []string) []string {
+	var withRelated []string
+	for _, category := range categories {
+		all := append(append([]string{}, withRelated...), categories...)
+		for _, related := range missingRelatedCategories(category, all) {
+			if !containsString(withRelated, related) {
+				withRelated = append(withRelated, related)
+			}
+		}
+		if !containsString(withRelated, category) {
+			withRelated = append(withRelated, category)
+		}
+	}
+	return withRelated
+}
@@ -0,0 +1,67 @@
+package gendesk
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Categories are registered, unique and come with the related categories they require
+func TestNormalizeCategories(t *testing.T) {
+	tests := []struct {
+		categories []string
+		expected   []string
+		warnings   int
+	}{
+		{[]string{"Graphics", "Viewer"}, []string{"Graphics", "Viewer"}, 0},
+		{[]string{"graphics", " Viewer ", "", "Graphics", "Application"}, []string{"Graphics", "Viewer"}, 0},
+		{[]string{"TextEditor"}, []string{"Utility", "TextEditor"}, 0},
+		{[]string{"Dictionary"}, []string{"Office", "Utility", "TextTools", "Dictionary"}, 0},
+		{[]string{"Game", "Emulator"}, []string{"Game", "Emulator"}, 0},
+		{[]string{"Emulator"}, []string{"System", "Emulator"}, 0},
+		{[]string{"Utility", "X-Foo", "Bar"}, []string{"Utility", "X-Foo", "X-Bar"}, 1},
+	}
+	for _, test := range tests {
+		normalized, warnings := normalizeCategories(test.categories)
+		if !reflect.DeepEqual(normalized, test.expected) {
+			t.Errorf("%q: got %q, expected %q", test.categories, normalized, test.expected)
+		}
+		if len(warnings) != test.warnings {
+			t.Errorf("%q: got the warnings %q", test.categories, warnings)
+		}
+	}
+}
+
+// Unregistered categories are errors, while the other problems with Categories are warnings
+func TestValidateCategories(t *testing.T) {
+	tests := []struct {
+		categories string
+		problem    string // a part of the expected problem, or "" for none
+		isError    bool
+	}{
+		{"Utility;TextEditor;", "", false},
+		{"Utility;X-Foo;", "", false},
+		{"Utility;Foo;", "not registered", true},
+		{"TextEditor;", "should be used together with Utility", false},
+		{"Dictionary;", "Office and TextTools", false},
+		{"Utility;Utility;", "more than once", false},
+		{"Utility;Application;", "Application", false},
+		{"Viewer;", "at least one of the registered main categories", false},
+	}
+	for _, test := range tests {
+		contents := "[Desktop Entry]\nType=Application\nName=Foo\nExec=foo\nCategories=" + test.categories + "\n"
+		problems := Validate([]byte(contents))
+		var messages []string
+		for _, problem := range problems {
+			messages = append(messages, problem.Message)
+		}
+		switch {
+		case test.problem == "" && len(problems) > 0:
+			t.Errorf("Categories=%s: unexpected problem: %s", test.categories, problems[0].Message)
+		case test.problem != "" && !strings.Contains(strings.Join(messages, "\n"), test.problem):
+			t.Errorf("Categories=%s: expected a problem with %q, got %v", test.categories, test.problem, problems)
+		case test.problem != "" && HasErrors(problems) != test.isError:
+			t.Errorf("Categories=%s: expected the problem to be an error: %v", test.categories, test.isError)
+		}
+	}
+}
@@ -0,0 +1,338 @@
+package gendesk
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/user"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// A rule for guessing categories. Each of the keywords that is found in the package
+// description, the package name or the URL, and each of the dependencies and package
+// groups that match, adds the weight of the rule to the score of the categories.
+//
+// In a category rules file, a rule with the same name as an existing rule changes
+// that rule, while other rules are added at the end, or before the rule given
+// with "before". A rule can be removed by setting "disabled" to true.
+type CategoryRule struct {
+	Name        string   `json:"name"`
+	Keywords    []string `json:"keywords,omitempty"`     // replaces the keywords of an existing rule
+	AddKeywords []string `json:"add_keywords,omitempty"` // extends the keywords of an existing rule
+	Depends     []string `json:"depends,omitempty"`      // dependencies, like "sdl2" or "gst-plugins-*"
+	Groups      []string `json:"groups,omitempty"`       // package groups, like "kde-games" or "*-games"
+	Categories  string   `json:"categories,omitempty"`   // like "Game;BoardGame"
+	Weight      float64  `json:"weight,omitempty"`       // 1 if not given
+	Before      string   `json:"before,omitempty"`       // the name of the rule to place this rule in front of
+	Disabled    bool     `json:"disabled,omitempty"`
+}
+
+// The contents of a category rules file
+type categoryRulesFile struct {
+	Rules []CategoryRule `json:"rules"`
+}
+
+// The category rules files that are read, if they exist. The later ones take precedence.
+func categoryRulesFilenames() []string {
+	filenames := []string{"/etc/gendesk/categories.json", "/etc/gendesk/categories.toml"}
+	configHome := os.Getenv("XDG_CONFIG_HOME")
+	if configHome == "" {
+		if usr, err := user.Current(); err == nil {
+			configHome = filepath.Join(usr.HomeDir, ".config")
+		}
+	}
+	if configHome != "" {
+		filenames = append(filenames, filepath.Join(configHome, "gendesk", "categories.json"), filepath.Join(configHome, "gendesk", "categories.toml"))
+	}
+	return filenames
+}
+
+// Return the built-in category rules, merged with the rules from the system-wide and
+// the per-user category rules files, if present, and then the given file, if any.
+func LoadCategoryRules(filename string) ([]CategoryRule, error) {
+	rules := defaultCategoryRules
+	for _, f := range categoryRulesFilenames() {
+		if _, err := os.Stat(f); err != nil {
+			continue
+		}
+		var err error
+		if rules, err = mergeCategoryRulesFile(rules, f); err != nil {
+			return nil, err
+		}
+	}
+	if filename != "" {
+		return mergeCategoryRulesFile(rules, filename)
+	}
+	return rules, nil
+}
+
+// Read a category rules file and merge it with the given rules
+func mergeCategoryRulesFile(rules []CategoryRule, filename string) ([]CategoryRule, error) {
+	overrides, err := readCategoryRules(filename)
+	if err == nil {
+		rules, err = mergeCategoryRules(rules, overrides)
+	}
+	if err != nil {
+		return nil, errors.New(filename + ": " + err.Error())
+	}
+	return rules, nil
+}
+
+// Read the rules from a JSON file, or from a TOML file if the filename ends with .toml
+func readCategoryRules(filename string) ([]CategoryRule, error) {
+	filedata, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	if strings.HasSuffix(filename, ".toml") {
+		return parseCategoryRulesTOML(string(filedata))
+	}
+	var file categoryRulesFile
+	if err := json.Unmarshal(filedata, &file); err != nil {
+		return nil, err
+	}
+	return file.Rules, nil
+}
+
+// Write rules as a JSON category rules file, that can be read by LoadCategoryRules
+func WriteCategoryRules(w io.Writer, rules []CategoryRule) error {
+	data, err := json.MarshalIndent(categoryRulesFile{rules}, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// Merge rules from a file into the existing rules, keeping the order of the existing rules
+func mergeCategoryRules(rules, overrides []CategoryRule) ([]CategoryRule, error) {
+	// Don't modify the given rules
+	merged := make([]CategoryRule, len(rules))
+	copy(merged, rules)
+	for _, override := range overrides {
+		if override.Name == "" {
+			return nil, errors.New("all category rules must have a name")
+		}
+		pos := categoryRuleIndex(merged, override.Name)
+		if override.Disabled {
+			if pos != -1 {
+				merged = append(merged[:pos], merged[pos+1:]...)
+			}
+			continue
+		}
+		var rule CategoryRule
+		if pos != -1 {
+			rule = merged[pos]
+			merged = append(merged[:pos], merged[pos+1:]...)
+		} else {
+			rule = CategoryRule{Name: override.Name}
+		}
+		if override.Keywords != nil {
+			rule.Keywords = override.Keywords
+		}
+		var keywords []string
+		for _, keyword := range append(append([]string{}, rule.Keywords...), override.AddKeywords...) {
+			// Keywords are compared with the lowercase words of the description
+			keywords = append(keywords, strings.ToLower(keyword))
+		}
+		rule.Keywords = keywords
+		if override.Depends != nil {
+			rule.Depends = override.Depends
+		}
+		if override.Groups != nil {
+			rule.Groups = override.Groups
+		}
+		if override.Categories != "" {
+			rule.Categories = override.Categories
+		}
+		if override.Weight != 0 {
+			rule.Weight = override.Weight
+		}
+		if len(rule.Keywords)+len(rule.Depends)+len(rule.Groups) == 0 || len(splitList(rule.Categories)) == 0 {
+			return nil, errors.New("the category rule " + rule.Name + " needs categories and keywords, depends or groups")
+		}
+		for _, pattern := range append(append([]string{}, rule.Depends...), rule.Groups...) {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, errors.New("the category rule " + rule.Name + " has an invalid pattern: " + pattern)
+			}
+		}
+		// Place the rule where it was, or in front of the given rule, or at the end
+		switch {
+		case override.Before != "":
+			pos = categoryRuleIndex(merged, override.Before)
+			if pos == -1 {
+				return nil, errors.New("the category rule " + rule.Name + " should be placed before " + override.Before + ", which does not exist")
+			}
+		case pos == -1:
+			pos = len(merged)
+		}
+		merged = append(merged[:pos], append([]CategoryRule{rule}, merged[pos:]...)...)
+	}
+	return merged, nil
+}
+
+// Return the position of the rule with the given name, or -1
+func categoryRuleIndex(rules []CategoryRule, name string) int {
+	for i, rule := range rules {
+		if rule.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// Parse category rules in TOML, given as an array of [[rule]] tables with strings,
+// arrays of strings, numbers and booleans as values. Arrays may span several lines.
+func parseCategoryRulesTOML(contents string) ([]CategoryRule, error) {
+	var (
+		rules   []CategoryRule
+		current *CategoryRule
+		pending string // an array that continues on the next line
+		start   int    // the line the pending array started on
+	)
+	for i, line := range strings.Split(contents, "\n") {
+		line = strings.TrimSpace(stripTOMLComment(line))
+		if pending != "" {
+			pending += " " + line
+			if !strings.HasSuffix(pending, "]") {
+				continue
+			}
+			line, pending = pending, ""
+		} else {
+			start = i + 1
+		}
+		switch {
+		case line == "":
+			continue
+		case line == "[[rule]]" || line == "[[rules]]":
+			rules = append(rules, CategoryRule{})
+			current = &rules[len(rules)-1]
+			continue
+		case strings.HasPrefix(line, "["):
+			return nil, errors.New("line " + strconv.Itoa(start) + ": unknown table " + line + ", expected [[rule]]")
+		}
+		pos := strings.Index(line, "=")
+		if pos == -1 {
+			return nil, errors.New("line " + strconv.Itoa(start) + ": expected key = value")
+		}
+		key, value := strings.TrimSpace(line[:pos]), strings.TrimSpace(line[pos+1:])
+		if strings.HasPrefix(value, "[") && !strings.HasSuffix(value, "]") {
+			pending = line
+			continue
+		}
+		if current == nil {
+			return nil, errors.New("line " + strconv.Itoa(start) + ": " + key + " must be placed in a [[rule]] table")
+		}
+		if err := current.setTOML(key, value); err != nil {
+			return nil, errors.New("line " + strconv.Itoa(start) + ": " + err.Error())
+		}
+	}
+	if pending != "" {
+		return nil, errors.New("line " + strconv.Itoa(start) + ": unterminated array")
+	}
+	return rules, nil
+}
+
+// Set a field of a rule from a TOML key and value
+func (rule *CategoryRule) setTOML(key, value string) error {
+	var err error
+	switch key {
+	case "name":
+		rule.Name, err = parseTOMLString(value)
+	case "keywords":
+		rule.Keywords, err = parseTOMLArray(value)
+	case "add_keywords":
+		rule.AddKeywords, err = parseTOMLArray(value)
+	case "depends":
+		rule.Depends, err = parseTOMLArray(value)
+	case "groups":
+		rule.Groups, err = parseTOMLArray(value)
+	case "categories":
+		rule.Categories, err = parseTOMLString(value)
+	case "weight":
+		rule.Weight, err = strconv.ParseFloat(value, 64)
+	case "before":
+		rule.Before, err = parseTOMLString(value)
+	case "disabled":
+		rule.Disabled, err = strconv.ParseBool(value)
+	default:
+		return errors.New("unknown key " + key)
+	}
+	if err != nil {
+		return errors.New("invalid value for " + key + ": " + value)
+	}
+	return nil
+}
+
+// Remove a # comment from a line, unless it is within a string
+func stripTOMLComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; {
+		case quote == '"' && c == '\\':
+			i++
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote == 0 && (c == '"' || c == '\''):
+			quote = c
+		case quote == 0 && c == '#':
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// Parse a TOML string, either "basic" or 'literal'
+func parseTOMLString(value string) (string, error) {
+	if len(value) >= 2 && value[0] == '\'' && value[len(value)-1] == '\'' {
+		return value[1 : len(value)-1], nil
+	}
+	if len(value) < 2 || value[0] != '"' || value[len(value)-1] != '"' {
+		return "", errors.New("expected a string")
+	}
+	return strconv.Unquote(value)
+}
+
+// Parse a TOML array of strings
+func parseTOMLArray(value string) ([]string, error) {
+	if len(value) < 2 || value[0] != '[' || value[len(value)-1] != ']' {
+		return nil, errors.New("expected an array")
+	}
+	elements := []string{}
+	rest := strings.TrimSpace(value[1 : len(value)-1])
+	for rest != "" {
+		// Find the end of the string
+		end := -1
+		if len(rest) > 0 && (rest[0] == '"' || rest[0] == '\'') {
+			for i := 1; i < len(rest); i++ {
+				if rest[0] == '"' && rest[i] == '\\' {
+					i++
+				} else if rest[i] == rest[0] {
+					end = i
+					break
+				}
+			}
+		}
+		if end == -1 {
+			return nil, errors.New("expected a string")
+		}
+		element, err := parseTOMLString(rest[:end+1])
+		if err != nil {
+			return nil, err
+		}
+		elements = append(elements, element)
+		rest = strings.TrimSpace(rest[end+1:])
+		// Elements are separated by commas, and a trailing comma is allowed
+		if strings.HasPrefix(rest, ",") {
+			rest = strings.TrimSpace(rest[1:])
+		} else if rest != "" {
+			return nil, errors.New("expected a comma")
+		}
+	}
+	return elements, nil
+}
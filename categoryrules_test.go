@@ -0,0 +1,165 @@
+package gendesk
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Rules in TOML are read as the same rules in JSON
+func TestParseCategoryRulesTOML(t *testing.T) {
+	contents := `# Rules for games
+[[rule]]
+name = "chess" # a comment
+keywords = [
+  "chess",   # the game
+  'xboard',
+]
+categories = "Game;BoardGame"
+weight = 2.5
+before = "strategy"
+
+[[rule]]
+name = "c#"
+add_keywords = ["c# ide", "mono \"develop\""]
+depends = ["mono*"]
+groups = []
+disabled = true
+`
+	rules, err := parseCategoryRulesTOML(contents)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []CategoryRule{
+		{Name: "chess", Keywords: []string{"chess", "xboard"}, Categories: "Game;BoardGame", Weight: 2.5, Before: "strategy"},
+		{Name: "c#", AddKeywords: []string{"c# ide", "mono \"develop\""}, Depends: []string{"mono*"}, Groups: []string{}, Disabled: true},
+	}
+	if !reflect.DeepEqual(rules, expected) {
+		t.Errorf("got %+v, expected %+v", rules, expected)
+	}
+	invalid := []string{
+		"name = \"outside of a table\"",
+		"[rule]\nname = \"foo\"",
+		"[[rule]]\nkeywords = [\"unterminated\",",
+		"[[rule]]\nkeyword = [\"foo\"]",
+		"[[rule]]\nweight = heavy",
+		"[[rule]]\nname = foo",
+		"[[rule]]\nkeywords = [\"a\" \"b\"]",
+	}
+	for _, contents := range invalid {
+		if _, err := parseCategoryRulesTOML(contents); err == nil {
+			t.Errorf("%q: expected an error", contents)
+		}
+	}
+}
+
+// Rules from a file change, remove, add and move the existing rules
+func TestMergeCategoryRules(t *testing.T) {
+	rules := []CategoryRule{
+		{Name: "a", Keywords: []string{"alpha"}, Categories: "Office"},
+		{Name: "b", Keywords: []string{"beta"}, Categories: "Game"},
+		{Name: "c", Depends: []string{"gamma"}, Categories: "Network"},
+	}
+	overrides := []CategoryRule{
+		{Name: "b", AddKeywords: []string{"Beta Two"}, Weight: 3},
+		{Name: "c", Disabled: true},
+		{Name: "d", Groups: []string{"*-games"}, Categories: "Game"},
+		{Name: "e", Keywords: []string{"epsilon"}, Categories: "Science", Before: "a"},
+		{Name: "a", Keywords: []string{"ALPHA"}},
+		{Name: "nonexistent", Disabled: true},
+	}
+	merged, err := mergeCategoryRules(rules, overrides)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []CategoryRule{
+		{Name: "e", Keywords: []string{"epsilon"}, Categories: "Science"},
+		{Name: "a", Keywords: []string{"alpha"}, Categories: "Office"},
+		{Name: "b", Keywords: []string{"beta", "beta two"}, Categories: "Game", Weight: 3},
+		{Name: "d", Groups: []string{"*-games"}, Categories: "Game"},
+	}
+	if !reflect.DeepEqual(merged, expected) {
+		t.Errorf("got %+v, expected %+v", merged, expected)
+	}
+	if rules[1].Weight != 0 || len(rules) != 3 {
+		t.Errorf("the given rules were modified: %+v", rules)
+	}
+	invalid := []CategoryRule{
+		{Keywords: []string{"foo"}, Categories: "Game"},
+		{Name: "f", Keywords: []string{"foo"}},
+		{Name: "f", Categories: "Game"},
+		{Name: "f", Depends: []string{"[foo"}, Categories: "Game"},
+		{Name: "f", Keywords: []string{"foo"}, Categories: "Game", Before: "z"},
+	}
+	for _, override := range invalid {
+		if _, err := mergeCategoryRules(rules, []CategoryRule{override}); err == nil {
+			t.Errorf("%+v: expected an error", override)
+		}
+	}
+}
+
+// The per-user rules and the given rules file are merged with the built-in rules,
+// and written rules can be read back
+func TestLoadCategoryRules(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gendesk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer os.Setenv("XDG_CONFIG_HOME", os.Getenv("XDG_CONFIG_HOME"))
+	os.Setenv("XDG_CONFIG_HOME", dir)
+
+	if err := os.MkdirAll(filepath.Join(dir, "gendesk"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	toml := "[[rule]]\nname = \"frobnicate\"\nkeywords = [\"frobnicator\"]\ncategories = \"Science;Physics\"\n"
+	if err := ioutil.WriteFile(filepath.Join(dir, "gendesk", "categories.toml"), []byte(toml), 0644); err != nil {
+		t.Fatal(err)
+	}
+	json := `{"rules": [{"name": "frobnicate", "categories": "Game;Simulation"}]}`
+	filename := filepath.Join(dir, "rules.json")
+	if err := ioutil.WriteFile(filename, []byte(json), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	info := PackageInfo{Pkgname: "frob", Pkgdesc: "A frobnicator"}
+	rules, err := LoadCategoryRules("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if categories := GuessCategory(info, rules); !strings.Contains(categories, "Physics") {
+		t.Errorf("expected the per-user rule to be used, got %s", categories)
+	}
+	rules, err = LoadCategoryRules(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if categories := GuessCategory(info, rules); !strings.Contains(categories, "Simulation") {
+		t.Errorf("expected the rules file to override the per-user rule, got %s", categories)
+	}
+	if len(rules) != len(defaultCategoryRules)+1 {
+		t.Errorf("expected one rule to be added to the built-in rules, got %d rules", len(rules))
+	}
+
+	if err := ioutil.WriteFile(filename, []byte(`{"rules": [{"keywords": ["foo"], "categories": "Game"}]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadCategoryRules(filename); err == nil || !strings.HasPrefix(err.Error(), filename+": ") {
+		t.Errorf("expected an error about %s, got %v", filename, err)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteCategoryRules(&buf, defaultCategoryRules); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filename, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if written, err := readCategoryRules(filename); err != nil || !reflect.DeepEqual(written, defaultCategoryRules) {
+		t.Errorf("the written rules could not be read back: %v", err)
+	}
+}
@@ -1,10 +1,10 @@
 #!/bin/sh
 
 echo -n go fmt...
-go fmt && echo ok || echo fail
+go fmt ./... && echo ok || echo fail
 
 echo -n go vet...
-go vet && echo ok || echo fail
+go vet ./... && echo ok || echo fail
 
 # github.com/golang/lint/golint
 #echo -n golint...
@@ -12,10 +12,10 @@ go vet && echo ok || echo fail
 
 # tools from https://github.com/dominikh/go-tools
 echo -n gosimple...
-gosimple && echo ok || echo fail
+gosimple ./... && echo ok || echo fail
 
 echo -n unused...
-gosimple && echo ok || echo fail
+gosimple ./... && echo ok || echo fail
 
 echo -n staticcheck...
-staticcheck && echo ok || echo fail
+staticcheck ./... && echo ok || echo fail
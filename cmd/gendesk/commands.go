@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/xyproto/gendesk"
+	"github.com/xyproto/term"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// The filename for the learned category rules
+const learnedRulesFilename = "categories.json"
+
+// Validate the given .desktop files and output the problems that are found.
+// Returns the exit code, which is 1 if any of the files contain errors.
+func validateFiles(filenames []string, o *term.TextOutput) int {
+	if len(filenames) == 0 {
+		o.Err("No .desktop files given")
+		return 1
+	}
+	exitCode := 0
+	for _, filename := range filenames {
+		filedata, err := ioutil.ReadFile(filename)
+		if err != nil {
+			o.Err("Could not read " + filename)
+			exitCode = 1
+			continue
+		}
+		problems := gendesk.Validate(filedata)
+		for _, problem := range problems {
+			location := filename
+			if problem.Line > 0 {
+				location += ":" + strconv.Itoa(problem.Line)
+			}
+			if problem.Warning {
+				o.Println(o.DarkGray(location+": ") + o.DarkYellow("warning: ") + problem.Message)
+			} else {
+				o.Println(o.DarkGray(location+": ") + o.DarkRed("error: ") + problem.Message)
+			}
+		}
+		if gendesk.HasErrors(problems) {
+			exitCode = 1
+		}
+	}
+	return exitCode
+}
+
+// Read the .desktop files in the given directories, and write the learned category
+// rules to categories.json in the current directory. Returns the exit code.
+func learnCategories(dirs []string, force bool, o *term.TextOutput) int {
+	if len(dirs) == 0 {
+		o.Err("Usage: gendesk learn-categories DIR...")
+		return 1
+	}
+	rules, files, warnings, err := gendesk.LearnCategoryRules(dirs)
+	for _, warning := range warnings {
+		o.Err(warning)
+	}
+	if err != nil {
+		o.Err("Could not learn categories: " + err.Error())
+		return 1
+	}
+
+	// Check if the file exists (and that force is not enabled)
+	if _, err := os.Stat(learnedRulesFilename); err == nil && (!force) {
+		o.Err(learnedRulesFilename + " already exists. Use -f as the first argument to overwrite it.")
+		return 1
+	}
+	var buf bytes.Buffer
+	if err := gendesk.WriteCategoryRules(&buf, rules); err != nil {
+		o.Err(err.Error())
+		return 1
+	}
+	if err := ioutil.WriteFile(learnedRulesFilename, buf.Bytes(), 0666); err != nil {
+		o.Err("Could not write " + learnedRulesFilename + ": " + err.Error())
+		return 1
+	}
+	keywords := 0
+	for _, rule := range rules {
+		keywords += len(rule.Keywords)
+	}
+	o.Println(fmt.Sprintf("Learned %d keywords for %d categories from %d .desktop files, wrote %s", keywords, len(rules), files, learnedRulesFilename))
+	return 0
+}
+
+// Install the given icons into the hicolor icon theme within destdir, named after the
+// package, or after the icon files if no package name is given. Returns the exit code.
+func installIcons(filenames []string, pkgname, destdir string, scale bool, o *term.TextOutput) int {
+	if len(filenames) == 0 {
+		o.Err("Usage: gendesk [--pkgname=PKGNAME] [--destdir=DIR] [--scale-icons] install-icons ICON...")
+		return 1
+	}
+	if destdir == "" {
+		o.Err("No directory to install icons into. Use --destdir or set $pkgdir.")
+		return 1
+	}
+	for _, filename := range filenames {
+		data, err := ioutil.ReadFile(filename)
+		if err != nil {
+			o.Err("Could not read " + filename)
+			return 1
+		}
+		name := strings.TrimSuffix(pkgname, "-git")
+		if name == "" {
+			name = strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename))
+		}
+		// Convert the icon to PNG or SVG, if needed, and check that it is square
+		icon, err := gendesk.NormalizeIcon(data, gendesk.DefaultIconRules)
+		if err != nil {
+			o.Err(filename + ": " + err.Error())
+			return 1
+		}
+		installed, err := gendesk.InstallIcon(destdir, name, icon.Data, scale)
+		for _, installedFilename := range installed {
+			o.Println("Installed " + installedFilename)
+		}
+		if err != nil {
+			o.Err(filename + ": " + err.Error())
+			return 1
+		}
+	}
+	return 0
+}
+
+// Install generated placeholder icons for the package into the hicolor icon theme
+// within destdir, at each of the standard sizes. Returns the exit code.
+func installPlaceholderIcons(pkgname, name, destdir string, svg bool, o *term.TextOutput) int {
+	pkgname = strings.TrimSuffix(pkgname, "-git")
+	if pkgname == "" {
+		o.Err("Usage: gendesk --pkgname=PKGNAME [--name=NAME] [--destdir=DIR] [--placeholder-svg] --placeholder install-icons")
+		return 1
+	}
+	if destdir == "" {
+		o.Err("No directory to install icons into. Use --destdir or set $pkgdir.")
+		return 1
+	}
+	if name == "" {
+		name = pkgname
+	}
+	installed, err := gendesk.InstallPlaceholderIcons(destdir, pkgname, name, svg)
+	for _, installedFilename := range installed {
+		o.Println("Installed " + installedFilename)
+	}
+	if err != nil {
+		o.Err(err.Error())
+		return 1
+	}
+	return 0
+}
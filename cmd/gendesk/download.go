@@ -0,0 +1,207 @@
+package main
+
+import (
+	"errors"
+	"github.com/akrennmair/goconf"
+	"github.com/xyproto/gendesk"
+	"github.com/xyproto/term"
+	"io/ioutil"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+)
+
+// Read the first configuration file that is found, or return nil and ""
+func readConfigFile() (*conf.ConfigFile, string) {
+	var filenames []string
+	if usr, err := user.Current(); err == nil {
+		filenames = append(filenames, filepath.Join(usr.HomeDir, ".gendeskrc"), filepath.Join(usr.HomeDir, ".config", "gendesk"))
+	}
+	filenames = append(filenames, "/etc/gendeskrc")
+	for _, filename := range filenames {
+		if cfile, err := conf.ReadConfigFile(filename); err == nil {
+			return cfile, filename
+		}
+	}
+	return nil, ""
+}
+
+// Output an example of how icon providers are configured, and exit
+func iconConfigError(cfilename, message string, o *term.TextOutput) {
+	o.Err("error!\n")
+	o.Println(o.DarkRed(cfilename + ": " + message + ". Example:"))
+	o.Println(o.LightGreen("[default]"))
+	o.Println(o.LightGreen("icon_providers = mirror theme\n"))
+	o.Println(o.LightGreen("[mirror]"))
+	o.Println(o.LightGreen("type = url"))
+	o.Println(o.LightGreen("url = http://some.iconrepository.com/%s.png\n"))
+	o.Println(o.LightGreen("[theme]"))
+	o.Println(o.LightGreen("type = theme"))
+	o.Println(o.LightGreen("theme = hicolor"))
+	os.Exit(1)
+}
+
+// Check if an icon URL template points to a remote site, and not to a local file
+func isRemoteURL(iconURL string) bool {
+	return !strings.HasPrefix(iconURL, "file://")
+}
+
+// Find the icon providers, in the order they should be tried, from the given configuration
+// file, which may be nil. The icon_providers key under the [default] section lists the
+// sections with the providers, while icon_url gives a single URL template. The installed
+// icon themes, with the given theme and size, are searched first, unless a provider with
+// type = theme is configured, and then the icon URL from the sources of the package, if
+// any. If download is false, icons are not downloaded from http:// or https:// URLs, but
+// the other providers are still used.
+func iconProviders(cfile *conf.ConfigFile, cfilename, theme string, size int, sourceURL string, download bool, o *term.TextOutput) []gendesk.IconProvider {
+	providers, hasTheme := configuredIconProviders(cfile, cfilename, theme, size, download, o)
+	if sourceURL != "" && (download || !isRemoteURL(sourceURL)) {
+		provider, err := gendesk.NewSingleURLIconProvider("source", sourceURL)
+		if err != nil {
+			o.ErrExit(err.Error())
+		}
+		providers = append([]gendesk.IconProvider{provider}, providers...)
+	}
+	if !hasTheme {
+		providers = append([]gendesk.IconProvider{gendesk.NewThemeIconProvider("installed icon themes", theme, size)}, providers...)
+	}
+	return providers
+}
+
+// Find the icon providers from the configuration file, or the default ones if there is
+// none, and if any of them is a theme provider
+func configuredIconProviders(cfile *conf.ConfigFile, cfilename, theme string, size int, download bool, o *term.TextOutput) ([]gendesk.IconProvider, bool) {
+	if cfile == nil {
+		if !download {
+			return nil, false
+		}
+		return gendesk.DefaultIconProviders(), false
+	}
+
+	list, err := cfile.GetString("default", "icon_providers")
+	if err != nil {
+		// Only an URL template, under the [default] section with the key icon_url
+		iconURL, err := cfile.GetString("default", "icon_url")
+		if err != nil {
+			iconConfigError(cfilename, "icon_providers or icon_url is missing from the [default] section", o)
+		}
+		// Older configuration files point icon_url at Open Icon Library, without any MD5 sums
+		hashes := notFoundMD5(cfile, "default")
+		if len(hashes) == 0 {
+			hashes = []string{gendesk.OpenIconLibraryNotFoundMD5}
+		}
+		provider, err := gendesk.NewURLIconProvider("icon_url", iconURL, hashes)
+		if err != nil {
+			iconConfigError(cfilename, err.Error(), o)
+		}
+		if !download && isRemoteURL(iconURL) {
+			return nil, false
+		}
+		return []gendesk.IconProvider{provider}, false
+	}
+
+	var providers []gendesk.IconProvider
+	hasTheme := false
+	for _, name := range strings.Fields(strings.Replace(list, ",", " ", -1)) {
+		if !cfile.HasSection(name) {
+			iconConfigError(cfilename, "the icon provider "+name+" has no ["+name+"] section", o)
+		}
+		typ, _ := cfile.GetString(name, "type")
+		switch typ {
+		case "url":
+			iconURL, err := cfile.GetString(name, "url")
+			if err != nil {
+				iconConfigError(cfilename, "the url key is missing from the ["+name+"] section", o)
+			}
+			provider, err := gendesk.NewURLIconProvider(name, iconURL, notFoundMD5(cfile, name))
+			if err != nil {
+				iconConfigError(cfilename, err.Error(), o)
+			}
+			if download || !isRemoteURL(iconURL) {
+				providers = append(providers, provider)
+			}
+		case "dir":
+			dir, err := cfile.GetString(name, "dir")
+			if err != nil {
+				iconConfigError(cfilename, "the dir key is missing from the ["+name+"] section", o)
+			}
+			providers = append(providers, gendesk.NewDirIconProvider(name, dir))
+		case "theme":
+			// The theme and size default to the ones given with --icon-theme and --icon-size
+			providerTheme, err := cfile.GetString(name, "theme")
+			if err != nil {
+				providerTheme = theme
+			}
+			providerSize, err := cfile.GetInt(name, "size")
+			if err != nil {
+				providerSize = size
+			}
+			providers = append(providers, gendesk.NewThemeIconProvider(name, providerTheme, providerSize))
+			hasTheme = true
+		default:
+			iconConfigError(cfilename, "the type of the icon provider "+name+" must be url, dir or theme", o)
+		}
+	}
+	return providers, hasTheme
+}
+
+// The MD5 sums of the placeholder images that an URL returns instead of an error, from
+// the not_found_md5 key in the given section, separated by spaces or commas
+func notFoundMD5(cfile *conf.ConfigFile, section string) []string {
+	list, err := cfile.GetString(section, "not_found_md5")
+	if err != nil {
+		return nil
+	}
+	return strings.Fields(strings.Replace(list, ",", " ", -1))
+}
+
+// Find the rules that icons must follow, from the icon_min_size and icon_allow_non_square
+// keys under the [default] section of the given configuration file, which may be nil
+func iconRules(cfile *conf.ConfigFile) gendesk.IconRules {
+	rules := gendesk.DefaultIconRules
+	if cfile == nil {
+		return rules
+	}
+	if minSize, err := cfile.GetInt("default", "icon_min_size"); err == nil {
+		rules.MinSize = minSize
+	}
+	if allowNonSquare, err := cfile.GetBool("default", "icon_allow_non_square"); err == nil {
+		rules.AllowNonSquare = allowNonSquare
+	}
+	return rules
+}
+
+// The icon names to look for, the package name first and then the name of the
+// application, in lowercase and with dashes instead of spaces
+func iconNames(pkgname, name string) []string {
+	names := []string{pkgname}
+	if alt := strings.ToLower(strings.Replace(name, " ", "-", -1)); alt != pkgname {
+		names = append(names, alt)
+	}
+	return names
+}
+
+// Find an icon with one of the given names with the icon providers, that follows the
+// rules, and write it to pkgname.png or pkgname.svg, where pkgname is the first of the names
+func WriteIconFile(names []string, providers []gendesk.IconProvider, rules gendesk.IconRules, o *term.TextOutput, force bool) error {
+	icon, reasons := gendesk.FindIcon(providers, rules, names...)
+	if icon == nil {
+		messages := make([]string, len(reasons))
+		for i, reason := range reasons {
+			messages[i] = reason.Error()
+		}
+		return errors.New(strings.Join(messages, ", "))
+	}
+	filename := names[0] + "." + icon.Format
+
+	// Check if the file exists (and that force is not enabled)
+	if _, err := os.Stat(filename); err == nil && (!force) {
+		o.ErrExit("no! " + filename + " already exists. Use -f to overwrite.")
+	}
+
+	if err := ioutil.WriteFile(filename, icon.Data, 0666); err != nil {
+		o.ErrExit("Could not write icon to " + filename + "!")
+	}
+	return nil
+}
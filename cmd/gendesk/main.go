@@ -0,0 +1,568 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/xyproto/gendesk"
+	"github.com/xyproto/term"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	version_string = "Desktop File Generator v.0.6.4"
+)
+
+var (
+	// Global flags
+	use_color = true
+	verbose   = true
+)
+
+func min(a int, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// Retrieve value from environment if the given value is empty
+func fromEnvIfEmpty(field *string, envVarName string) {
+	if *field == "" {
+		*field = os.Getenv(envVarName)
+	}
+}
+
+func dataFromEnvironment(pkgdesc, exec, name, genericname, mimetypes, comment, categories, custom *string) {
+	// Environment variables
+	fromEnvIfEmpty(pkgdesc, "pkgdesc")
+	fromEnvIfEmpty(exec, "_exec")
+	fromEnvIfEmpty(name, "_name")
+	fromEnvIfEmpty(genericname, "_genericname")
+	fromEnvIfEmpty(mimetypes, "_mimetypes")
+	fromEnvIfEmpty(mimetypes, "_mimetype")
+	fromEnvIfEmpty(comment, "_comment")
+	fromEnvIfEmpty(categories, "_categories")
+	fromEnvIfEmpty(custom, "_custom")
+}
+
+// Write the .pot file with the translatable fields of all the packages
+func writePotFile(filename string, catalog *gendesk.PotCatalog, force bool, o *term.TextOutput) {
+	// Check if the file exists (and that force is not enabled)
+	if _, err := os.Stat(filename); err == nil && (!force) {
+		o.ErrExit(filename + " already exists. Use -f as the first argument to overwrite it.")
+	}
+	var buf bytes.Buffer
+	catalog.WriteTo(&buf)
+	if err := ioutil.WriteFile(filename, buf.Bytes(), 0666); err != nil {
+		o.ErrExit("Could not write " + filename + ": " + err.Error())
+	}
+	o.Println("Wrote " + filename)
+}
+
+// Write the .desktop file for a package
+func writeDesktopFile(pkgname string, entry *gendesk.DesktopEntry, force bool, o *term.TextOutput) {
+	var buf bytes.Buffer
+	if _, err := entry.WriteTo(&buf); err != nil {
+		o.Err("no")
+		o.Println(pkgname + ".desktop could not be generated: " + err.Error())
+		os.Exit(1)
+	}
+
+	// The generated file should pass the same checks as the validate command
+	for _, problem := range gendesk.Validate(buf.Bytes()) {
+		o.Err(pkgname + ".desktop:" + strconv.Itoa(problem.Line) + ": " + problem.Message)
+	}
+
+	// Check if the file exists (and that force is not enabled)
+	if _, err := os.Stat(pkgname + ".desktop"); err == nil && (!force) {
+		o.Err("no")
+		o.Println(pkgname + ".desktop already exists. Use -f as the first argument to gendesk to overwrite.")
+		os.Exit(1)
+	}
+
+	ioutil.WriteFile(pkgname+".desktop", buf.Bytes(), 0666)
+}
+
+// WritePlaceholderIconFile generates a placeholder icon with the initials of the name,
+// and writes it to pkgname + ".png", and also to pkgname + ".svg" if svg is true
+func WritePlaceholderIconFile(pkgname, name string, size int, svg bool, o *term.TextOutput, force bool) error {
+	data, err := gendesk.PlaceholderPNG(pkgname, name, size)
+	if err != nil {
+		return err
+	}
+	files := map[string][]byte{pkgname + ".png": data}
+	if svg {
+		files[pkgname+".svg"] = gendesk.PlaceholderSVG(pkgname, name)
+	}
+	for filename := range files {
+		// Check if the file exists (and that force is not enabled)
+		if _, err := os.Stat(filename); err == nil && (!force) {
+			o.ErrExit("no! " + filename + " already exists. Use -f to overwrite.")
+		}
+	}
+	for filename, data := range files {
+		if err := ioutil.WriteFile(filename, data, 0666); err != nil {
+			return errors.New("could not write icon to " + filename)
+		}
+	}
+	return nil
+}
+
+// A flag for one of the optionalKeys. Falls back on the environment variable
+// if not given. Boolean flags may be given without a value.
+type optionalFlag struct {
+	value    string
+	variable string
+	boolean  bool
+}
+
+func (f *optionalFlag) String() string {
+	if f == nil {
+		return ""
+	}
+	return f.value
+}
+
+func (f *optionalFlag) Set(value string) error {
+	f.value = value
+	return nil
+}
+
+func (f *optionalFlag) IsBoolFlag() bool {
+	return f.boolean
+}
+
+// Return the value of the flag, or from the environment if it is not given
+func (f *optionalFlag) get() string {
+	if f.value == "" {
+		return os.Getenv(f.variable)
+	}
+	return f.value
+}
+
+// A flag that can be given several times, like --action
+type listFlag []string
+
+func (l *listFlag) String() string {
+	if l == nil {
+		return ""
+	}
+	return strings.Join(*l, "\n")
+}
+
+func (l *listFlag) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+func main() {
+	var filename string
+	version_help := "Show application name and version"
+	nodownload_help := "Don't download anything"
+	nocolor_help := "Don't use colors"
+	quiet_help := "Don't output anything on stdout"
+	force_help := "Overwrite .desktop files with the same name"
+	windowmanager_help := "Generate a .desktop file for launching a window manager"
+	pkgname_help := "The name of the package"
+	pkgdesc_help := "Description of the package"
+	name_help := "Name of the shortcut"
+	genericname_help := "Type of application"
+	comment_help := "Shortcut comment"
+	exec_help := "Path to executable"
+	execargs_help := "Arguments for the executable, like %U (%F is added if there are mime types)"
+	addkeywords_help := "Keywords to add to the given or generated keywords, separated by ;"
+	//iconurl_help := "URL to icon"
+	terminal_help := "Run the application in a terminal (default is false)"
+	categories_help := "Categories, see other .desktop files for examples"
+	mimetypes_help := "Mime types, see other .desktop files for examples"
+	startupnotify_help := "Notifcation when the application starts (default is false)"
+	custom_help := "Custom line to append at the end of the .desktop file"
+	action_help := "Launcher action as id:Name:Exec[:Icon], may be given several times"
+	translations_help := "File with localized values, like Name[de]=Name"
+	podir_help := "Directory with .po files (like de.po) to read localized values from"
+	categoryrules_help := "JSON or TOML file with rules for guessing categories"
+	icontheme_help := "Installed icon theme to look for the icon in first (default is hicolor)"
+	iconsize_help := "Preferred icon size when looking in icon themes (default is 48)"
+	destdir_help := "Directory to install icons into with install-icons (default is $pkgdir)"
+	scaleicons_help := "Also install downscaled copies of PNG icons at the smaller standard sizes"
+	placeholder_help := "Install generated placeholder icons with install-icons, instead of icon files"
+	placeholdersvg_help := "Also write an SVG version of generated placeholder icons"
+	explaincategory_help := "Explain how the categories are guessed, without writing any files"
+
+	flag.Usage = func() {
+		fmt.Println()
+		fmt.Println(version_string)
+		fmt.Println("generates .desktop files")
+		fmt.Println()
+		fmt.Println("Syntax: gendesk [flags] [PKGBUILD or .SRCINFO filename]")
+		fmt.Println("        gendesk [flags] validate FILE...")
+		fmt.Println("        gendesk [flags] extract-pot [PKGBUILD or .SRCINFO filename]")
+		fmt.Println("        gendesk [flags] learn-categories DIR...")
+		fmt.Println("        gendesk [flags] install-icons ICON...")
+		fmt.Println("        gendesk [flags] --placeholder install-icons")
+		fmt.Println()
+		fmt.Println("Possible flags:")
+		fmt.Println("    --version                    " + version_help)
+		fmt.Println("    -n                           " + nodownload_help)
+		fmt.Println("    --nocolor                    " + nocolor_help)
+		fmt.Println("    -q                           " + quiet_help)
+		fmt.Println("    -f                           " + force_help)
+		fmt.Println("    -wm                          " + windowmanager_help)
+		fmt.Println("    --pkgname=PKGNAME            " + pkgname_help)
+		fmt.Println("    --pkgdesc=PKGDESC            " + pkgdesc_help)
+		fmt.Println("    --name=NAME                  " + name_help)
+		fmt.Println("    --genericname=GENERICNAME    " + genericname_help)
+		fmt.Println("    --comment=COMMENT            " + comment_help)
+		fmt.Println("    --exec=EXEC                  " + exec_help)
+		fmt.Println("    --exec-args=ARGS             " + execargs_help)
+		fmt.Println("    --add-keywords=KEYWORDS      " + addkeywords_help)
+		fmt.Println("    --terminal=[true|false]      " + terminal_help)
+		fmt.Println("    --categories=CATEGORIES      " + categories_help)
+		fmt.Println("    --mimetypes=MIMETYPES        " + mimetypes_help)
+		fmt.Println("    --startupnotify=[true|false] " + startupnotify_help)
+		fmt.Println("    --custom=CUSTOM              " + custom_help)
+		fmt.Println("    --action=ACTION              " + action_help)
+		fmt.Println("    --translations=FILE          " + translations_help)
+		fmt.Println("    --podir=DIR                  " + podir_help)
+		fmt.Println("    --category-rules=FILE        " + categoryrules_help)
+		fmt.Println("    --icon-theme=THEME           " + icontheme_help)
+		fmt.Println("    --icon-size=SIZE             " + iconsize_help)
+		fmt.Println("    --destdir=DIR                " + destdir_help)
+		fmt.Println("    --scale-icons                " + scaleicons_help)
+		fmt.Println("    --placeholder                " + placeholder_help)
+		fmt.Println("    --placeholder-svg            " + placeholdersvg_help)
+		fmt.Println("    --explain-category           " + explaincategory_help)
+		fmt.Println("    --name[LOCALE]=NAME          Localized name, also for genericname, comment and")
+		fmt.Println("                                 keywords, like --comment[pt_BR]=COMMENT")
+		for _, k := range gendesk.OptionalKeys {
+			syntax := "--" + k.Option + "=" + strings.ToUpper(k.Option)
+			if k.IsBoolean() {
+				syntax = "--" + k.Option + "=[true|false]"
+			}
+			fmt.Printf("    %-28s %s\n", syntax, k.Help)
+		}
+		fmt.Println("    --help                       This text")
+		fmt.Println()
+		fmt.Println("Note:")
+		fmt.Println("    * Either provide a set of arguments, or a PKGBUILD file.")
+		fmt.Println("    * If pkgname and pkgdesc are provided, a PKGBUILD file is not needed.")
+		fmt.Println("    * \"$startdir/PKGBUILD\" is the default filename.")
+		fmt.Println("    * _exec in the PKGBUILD can be used to specifiy a different executable for the")
+		fmt.Println("      .desktop file. Example: _exec=('appname-gui')")
+		fmt.Println("    * The other flags can also be given as _variables in the PKGBUILD or as")
+		fmt.Println("      environment variables, like _keywords or _nodisplay.")
+		fmt.Println("    * Split PKGBUILD packages are supported.")
+		fmt.Println("    * A .SRCINFO file next to the PKGBUILD is used for pkgname, pkgdesc and source,")
+		fmt.Println("      if present.")
+		fmt.Println("    * If a .png or .svg icon is not found as a file or in the PKGBUILD, it is")
+		fmt.Println("      looked for in the installed icon themes, by the package name and then")
+		fmt.Println("      by the name. Then the icon providers in the configuration are tried in")
+		fmt.Println("      turn, or else")
+		shortname := strings.Split(gendesk.OpenIconLibraryURL, "/")
+		firstpart := strings.Join(shortname[:3], "/")
+		fmt.Println("      " + firstpart)
+		fmt.Println("      (This may or may not result in the icon you wished for).")
+		fmt.Println("    * Categories are guessed based on keywords in the package description,")
+		fmt.Println("      name and URL, and on the dependencies and groups, unless provided. The")
+		fmt.Println("      rules can be changed in /etc/gendesk/categories.json or")
+		fmt.Println("      ~/.config/gendesk/categories.json.")
+		fmt.Println("    * Keywords are generated from the package description and name, unless")
+		fmt.Println("      provided. More keywords can be added with --add-keywords.")
+		fmt.Println("    * Icons are assumed to be found in the hicolor icon theme once installed.")
+		fmt.Println("      install-icons places them in $pkgdir/usr/share/icons/hicolor/WxH/apps/,")
+		fmt.Println("      or in scalable/apps/ for SVG icons.")
+		fmt.Println()
+	}
+
+	version := flag.Bool("version", false, version_help)
+	nodownload := flag.Bool("n", false, nodownload_help)
+	nocolor := flag.Bool("nocolor", false, nocolor_help)
+	quiet := flag.Bool("q", false, quiet_help)
+	force := flag.Bool("f", false, force_help)
+	windowmanager := flag.Bool("wm", false, windowmanager_help)
+	givenPkgname := flag.String("pkgname", "", pkgname_help)
+	givenPkgdesc := flag.String("pkgdesc", "", pkgdesc_help)
+	name := flag.String("name", "", name_help)
+	genericname := flag.String("genericname", "", genericname_help)
+	comment := flag.String("comment", "", comment_help)
+	exec := flag.String("exec", "", exec_help)
+	execArgs := flag.String("exec-args", "", execargs_help)
+	extraKeywords := flag.String("add-keywords", "", addkeywords_help)
+	terminal := flag.Bool("terminal", false, terminal_help)
+	categories := flag.String("categories", "", categories_help)
+	mimetypes := flag.String("mimetypes", "", mimetypes_help)
+	mimetype := flag.String("mimetype", "", mimetypes_help)
+	custom := flag.String("custom", "", custom_help)
+	translationsFile := flag.String("translations", "", translations_help)
+	poDir := flag.String("podir", "", podir_help)
+	categoryRulesFile := flag.String("category-rules", "", categoryrules_help)
+	iconTheme := flag.String("icon-theme", "", icontheme_help)
+	iconSize := flag.String("icon-size", "", iconsize_help)
+	destdir := flag.String("destdir", "", destdir_help)
+	scaleIcons := flag.Bool("scale-icons", false, scaleicons_help)
+	placeholder := flag.Bool("placeholder", false, placeholder_help)
+	placeholderSVG := flag.Bool("placeholder-svg", false, placeholdersvg_help)
+	explainCategory := flag.Bool("explain-category", false, explaincategory_help)
+	startupnotify := flag.Bool("startupnotify", false, startupnotify_help)
+	var actions listFlag
+	flag.Var(&actions, "action", action_help)
+	optionalFlags := make(map[string]*optionalFlag)
+	for _, k := range gendesk.OptionalKeys {
+		f := &optionalFlag{variable: "_" + k.Option, boolean: k.IsBoolean()}
+		flag.Var(f, k.Option, k.Help)
+		optionalFlags[k.Key] = f
+	}
+	// Localized flags, like --name[de]=Name, are handled separately
+	remaining, localizedFlags, err := gendesk.ExtractLocalizedFlags(os.Args[1:])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	flag.CommandLine.Parse(remaining)
+	args := flag.Args()
+
+	// New output. Color? Enabled?
+	o := term.NewTextOutput(!*nocolor, !*quiet)
+
+	if *version {
+		o.Println(version_string)
+		os.Exit(0)
+	}
+
+	// Validate .desktop files instead of generating them
+	if len(args) > 0 && args[0] == "validate" {
+		os.Exit(validateFiles(args[1:], o))
+	}
+
+	// Learn keywords for guessing categories from existing .desktop files
+	if len(args) > 0 && args[0] == "learn-categories" {
+		os.Exit(learnCategories(args[1:], *force, o))
+	}
+
+	// Install icons into the hicolor icon theme within $pkgdir or the given directory
+	if len(args) > 0 && args[0] == "install-icons" {
+		fromEnvIfEmpty(destdir, "pkgdir")
+		fromEnvIfEmpty(destdir, "DESTDIR")
+		if *placeholder {
+			os.Exit(installPlaceholderIcons(*givenPkgname, *name, *destdir, *placeholderSVG, o))
+		}
+		os.Exit(installIcons(args[1:], *givenPkgname, *destdir, *scaleIcons, o))
+	}
+
+	// Write a .pot file with the translatable fields instead of .desktop files
+	var catalog *gendesk.PotCatalog
+	if len(args) > 0 && args[0] == "extract-pot" {
+		catalog = gendesk.NewPotCatalog()
+		args = args[1:]
+	}
+
+	pkgname := *givenPkgname
+	pkgdesc := *givenPkgdesc
+
+	// TODO: Write in a cleaner way
+	if pkgname == "" {
+		if len(args) == 0 {
+			if os.Getenv("pkgname") == "" {
+				if os.Getenv("SRCDEST") == "" {
+					filename = "../PKGBUILD"
+				} else {
+					// If SRCDEST is set, use that
+					filename = os.Getenv("SRCDEST") + "/PKGBUILD"
+				}
+			} else {
+				pkgname = os.Getenv("pkgname")
+			}
+		} else if len(args) >= 1 {
+			// args are non-flag arguments
+			filename = args[0]
+		}
+	}
+
+	// Environment variables
+	dataFromEnvironment(&pkgdesc, exec, name, genericname, mimetypes, comment, categories, custom)
+
+	var packages []gendesk.PackageInfo
+	if filename == "" {
+		// Use the arguments
+		info := gendesk.PackageInfo{
+			Pkgname:      strings.TrimSuffix(pkgname, "-git"),
+			Pkgdesc:      pkgdesc,
+			Exec:         *exec,
+			Name:         *name,
+			GenericName:  *genericname,
+			Comment:      *comment,
+			Custom:       *custom,
+			Translations: localizedFlags,
+		}
+		fromEnvIfEmpty(extraKeywords, "_add_keywords")
+		if *extraKeywords != "" {
+			info.SetVariable("_add_keywords", []string{*extraKeywords})
+		}
+		fromEnvIfEmpty(execArgs, "_exec_args")
+		info.ExecArgs = *execArgs
+		if *mimetype != "" {
+			info.SetVariable("_mimetypes", []string{*mimetype})
+		}
+		if *mimetypes != "" {
+			info.SetVariable("_mimetypes", []string{*mimetypes})
+		}
+		if *categories != "" {
+			info.SetVariable("_categories", []string{*categories})
+		}
+		fromEnvIfEmpty(translationsFile, "_translations")
+		info.TranslationsFile = *translationsFile
+		fromEnvIfEmpty(poDir, "_podir")
+		info.PODir = *poDir
+		if len(actions) > 0 {
+			info.Actions = actions
+		} else if os.Getenv("_actions") != "" {
+			// One action per line
+			info.Actions = strings.Split(os.Getenv("_actions"), "\n")
+		}
+		for _, k := range gendesk.OptionalKeys {
+			if value := optionalFlags[k.Key].get(); value != "" {
+				info.SetVariable("_"+k.Option, []string{value})
+			}
+		}
+		packages = []gendesk.PackageInfo{info}
+	} else {
+		// Read a PKGBUILD, and a .SRCINFO file next to it, if present
+		packages, err = gendesk.ReadPackages(filename)
+		if err != nil {
+			o.ErrExit("Could not read the packages: " + err.Error())
+		}
+	}
+
+	// The rules for guessing categories, from the built-in defaults and the category rules files
+	fromEnvIfEmpty(categoryRulesFile, "_category_rules")
+	categoryRules, err := gendesk.LoadCategoryRules(*categoryRulesFile)
+	if err != nil {
+		o.ErrExit("Could not read the category rules: " + err.Error())
+	}
+	// The preferred icon theme and size, for looking up installed icons
+	fromEnvIfEmpty(iconTheme, "_icon_theme")
+	fromEnvIfEmpty(iconSize, "_icon_size")
+	size := 48
+	if *iconSize != "" {
+		if size, err = strconv.Atoi(*iconSize); err != nil || size <= 0 {
+			o.ErrExit("Invalid icon size: " + *iconSize)
+		}
+	}
+
+	// The configuration file with the icon providers and the rules for icons, if any
+	cfile, cfilename := readConfigFile()
+
+	options := gendesk.Options{
+		WindowManager: *windowmanager,
+		Terminal:      *terminal,
+		StartupNotify: *startupnotify,
+		CategoryRules: categoryRules,
+		// The .po files are not needed when extracting a .pot file
+		SkipPO: catalog != nil,
+	}
+
+	// Write .desktop and .png icon for each package
+	for _, info := range packages {
+		pkgname := info.Pkgname
+		if strings.Contains(pkgname, "-nox") || strings.Contains(pkgname, "-cli") {
+			// Don't bother if it's a -nox or -cli package
+			continue
+		}
+		if *explainCategory {
+			pkgdesc := info.Pkgdesc
+			if pkgdesc == "" {
+				// Fall back on the package name
+				pkgdesc = pkgname
+			}
+			o.Println(pkgname + ": " + pkgdesc)
+			if info.Categories != nil {
+				o.Println("    Categories are given, not guessed: " + strings.Join(info.Categories, ";"))
+				continue
+			}
+			for _, line := range gendesk.ExplainCategories(info, categoryRules) {
+				o.Println("    " + line)
+			}
+			continue
+		}
+		entry, warnings, err := gendesk.NewDesktopEntry(info, options)
+		if err != nil {
+			o.ErrExit(pkgname + ": " + err.Error())
+		}
+		for _, warning := range warnings {
+			o.Err(pkgname + ": " + warning)
+		}
+
+		// Only collect the translatable fields, if extracting a .pot file
+		if catalog != nil {
+			catalog.AddEntry(pkgname, entry)
+			continue
+		}
+
+		// TODO: Refactor into a function
+		const nSpaces = 32
+		spaces := strings.Repeat(" ", nSpaces)[:nSpaces-min(nSpaces, len(pkgname))]
+		if o.IsEnabled() {
+			fmt.Printf("%s%s%s%s%s ",
+				o.DarkGray("["), o.LightBlue(pkgname),
+				o.DarkGray("]"), spaces,
+				o.DarkGray("Generating desktop file..."))
+		}
+
+		writeDesktopFile(pkgname, entry, *force, o)
+
+		if o.IsEnabled() {
+			fmt.Printf("%s\n", o.DarkGreen("ok"))
+		}
+
+		// TODO: Put in a function
+		// Download an icon if it's not downloaded by
+		// the PKGBUILD and not there already (.png or .svg)
+		pngFilenames, _ := filepath.Glob("*.png")
+		svgFilenames, _ := filepath.Glob("*.svg")
+		if 0 == (len(pngFilenames) + len(svgFilenames)) {
+			if len(pkgname) < 1 {
+				o.Err("No pkgname, can't download icon")
+			}
+			fmt.Printf("%s%s%s%s%s ",
+				o.DarkGray("["), o.LightBlue(pkgname),
+				o.DarkGray("]"), spaces,
+				o.DarkGray("Looking for an icon..."))
+			// With -n, only the providers that do not download anything are used
+			providers := iconProviders(cfile, cfilename, *iconTheme, size, info.IconURL, !*nodownload, o)
+			err := WriteIconFile(iconNames(pkgname, entry.Name), providers, iconRules(cfile), o, *force)
+			if err == nil {
+				if o.IsEnabled() {
+					fmt.Printf("%s\n", o.LightCyan("ok"))
+				}
+			} else {
+				if o.IsEnabled() {
+					fmt.Printf("%s %s\n", o.DarkYellow("no"), o.DarkGray("("+err.Error()+")"))
+					fmt.Printf("%s%s%s%s%s ",
+						o.DarkGray("["),
+						o.LightBlue(pkgname),
+						o.DarkGray("]"),
+						spaces,
+						o.DarkGray("Generating a placeholder icon instead..."))
+				}
+				if err := WritePlaceholderIconFile(pkgname, entry.Name, size, *placeholderSVG, o, *force); err != nil {
+					if o.IsEnabled() {
+						fmt.Println()
+					}
+					o.ErrExit(err.Error())
+				} else if o.IsEnabled() {
+					fmt.Printf("%s\n", o.LightPurple("yes"))
+				}
+			}
+		}
+	}
+
+	if catalog != nil && len(packages) > 0 {
+		writePotFile(packages[0].Pkgname+".pot", catalog, *force, o)
+	}
+}
@@ -0,0 +1,211 @@
+package gendesk
+
+import (
+	"bytes"
+	"errors"
+	"io"
+)
+
+// The contents of a .desktop file, for launching an application, or a window manager
+// if Type is XSession
+type DesktopEntry struct {
+	Type          string // Application or XSession
+	Name          string
+	GenericName   string
+	Comment       string
+	Exec          string // a command line, that is quoted as needed when written
+	Icon          string
+	Terminal      bool
+	StartupNotify bool
+	Categories    []string
+	MimeTypes     []string
+	// Additional keys, like Keywords or NoDisplay, written in the order of OptionalKeys
+	Optional map[string][]string
+	Actions  []Action
+	// Localized values for Name, GenericName, Comment, Keywords and the actions
+	Localized Translations
+	// Custom lines to add at the end of the [Desktop Entry] group
+	Custom string
+}
+
+// Options for creating desktop entries from packages
+type Options struct {
+	WindowManager bool // create an entry for launching a window manager
+	Terminal      bool
+	StartupNotify bool
+	// The rules for guessing categories, the built-in rules are used if nil
+	CategoryRules []CategoryRule
+	// Don't read the .po files, like when extracting a .pot file
+	SkipPO bool
+}
+
+// Create a desktop entry for a package. Values that are not given fall back on the
+// package name and description, while categories and keywords are guessed.
+// Returns the entry and warnings about the categories.
+func NewDesktopEntry(info PackageInfo, options Options) (*DesktopEntry, []string, error) {
+	rules := options.CategoryRules
+	if rules == nil {
+		rules = defaultCategoryRules
+	}
+	entry := &DesktopEntry{
+		Type:          "Application",
+		Name:          info.Name,
+		GenericName:   info.GenericName,
+		Comment:       info.Comment,
+		Icon:          info.Pkgname,
+		Terminal:      options.Terminal,
+		StartupNotify: options.StartupNotify,
+		MimeTypes:     info.MimeTypes,
+		Optional:      make(map[string][]string),
+		Custom:        info.Custom,
+	}
+	if options.WindowManager {
+		entry.Type = "XSession"
+	}
+	pkgdesc := info.Pkgdesc
+	if pkgdesc == "" {
+		// Fall back on the package name
+		pkgdesc = info.Pkgname
+	}
+	exec := info.Exec
+	if exec == "" {
+		// Fall back on the package name
+		exec = info.Pkgname
+	}
+	if entry.Name == "" {
+		// Fall back on the capitalized package name
+		entry.Name = capitalize(info.Pkgname)
+	}
+	if entry.Comment == "" {
+		// Fall back on pkgdesc
+		entry.Comment = pkgdesc
+	}
+	// Add the arguments, and a field code for opening files if needed
+	entry.Exec = execWithArgs(exec, info.ExecArgs, info.MimeTypes)
+	for key, values := range info.Optional {
+		entry.Optional[key] = values
+	}
+	actions, err := ParseActions(info.Actions)
+	if err != nil {
+		return nil, nil, err
+	}
+	entry.Actions = actions
+
+	// Keywords for searching, given or generated from what is known about the package
+	guess := guessCategories(info, rules)
+	keywords, found := entry.Optional["Keywords"]
+	if found {
+		keywords = splitList(keywords...)
+	} else {
+		keywords = generateKeywords(info.Pkgname, entry.Name, info.Pkgdesc, guess)
+	}
+	keywords = addKeywords(keywords, info.AddKeywords)
+	if len(keywords) > 0 {
+		entry.Optional["Keywords"] = keywords
+	}
+
+	// Localized values from a file come first, so that they can be overridden
+	entry.Localized = make(Translations)
+	if info.TranslationsFile != "" {
+		fileTranslations, err := readTranslationsFile(info.path(info.TranslationsFile))
+		if err != nil {
+			return nil, nil, errors.New("could not read translations: " + err.Error())
+		}
+		entry.Localized.merge(fileTranslations)
+	}
+	// Then the ones from .po files
+	if info.PODir != "" && !options.SkipPO {
+		catalogs, err := readPODir(info.path(info.PODir))
+		if err != nil {
+			return nil, nil, errors.New("could not read translations: " + err.Error())
+		}
+		entry.Localized.merge(translationsFromPO(catalogs, entry.Name, entry.GenericName, entry.Comment, keywords, actions))
+	}
+	entry.Localized.merge(info.Translations)
+
+	categories := info.Categories
+	if categories == nil {
+		categories = guess.categories
+	}
+	var warnings []string
+	entry.Categories, warnings = normalizeCategories(categories)
+	return entry, warnings, nil
+}
+
+// Write the contents of the .desktop file. Nothing is written if any of the values
+// can not be represented in a .desktop file.
+func (e *DesktopEntry) WriteTo(w io.Writer) (int64, error) {
+	var (
+		buf *bytes.Buffer
+		err error
+	)
+	if e.Type == "XSession" {
+		buf, err = e.windowManagerContents()
+	} else {
+		buf, err = e.contents()
+	}
+	if err != nil {
+		return 0, err
+	}
+	return buf.WriteTo(w)
+}
+
+// Generate the contents for the .desktop file (for executing a window manager)
+func (e *DesktopEntry) windowManagerContents() (*bytes.Buffer, error) {
+	w := newDesktopWriter()
+	w.group("Desktop Entry")
+	w.writeString("Type", "XSession")
+	w.writeExec("Exec", e.Exec)
+	w.writeProgram("TryExec", e.Exec)
+	w.writeString("Name", e.Name)
+	w.writeLocalized("Name", e.Localized)
+	if e.Custom != "" {
+		// Write the custom string to the end of the .desktop file (may contain \n)
+		w.writeCustom(e.Custom)
+	}
+	return w.result()
+}
+
+// Generate the contents for the .desktop file (for executing a desktop application)
+func (e *DesktopEntry) contents() (*bytes.Buffer, error) {
+	w := newDesktopWriter()
+	w.group("Desktop Entry")
+	w.writeString("Type", e.Type)
+	w.writeString("Name", e.Name)
+	w.writeLocalized("Name", e.Localized)
+	if e.GenericName != "" {
+		w.writeString("GenericName", e.GenericName)
+	}
+	w.writeLocalized("GenericName", e.Localized)
+	w.writeString("Comment", e.Comment)
+	w.writeLocalized("Comment", e.Localized)
+	w.writeExec("Exec", e.Exec)
+	w.writeString("Icon", e.Icon)
+	w.writeBool("Terminal", e.Terminal)
+	w.writeBool("StartupNotify", e.StartupNotify)
+	if len(e.Categories) > 0 {
+		w.writeList("Categories", e.Categories)
+	}
+	if len(e.MimeTypes) > 0 {
+		w.writeList("MimeType", e.MimeTypes)
+	}
+	// Additional keys, in the order they are listed in OptionalKeys
+	for _, k := range OptionalKeys {
+		if values, found := e.Optional[k.Key]; found {
+			w.writeValue(k.Key, values)
+		}
+		if k.Key == "Keywords" {
+			w.writeLocalized(k.Key, e.Localized)
+		}
+	}
+	if len(e.Actions) > 0 {
+		w.writeActionsKey(e.Actions)
+	}
+	if e.Custom != "" {
+		// Write the custom string to the end of the .desktop file (may contain \n)
+		w.writeCustom(e.Custom)
+	}
+	// The [Desktop Action id] groups come after the [Desktop Entry] group
+	w.writeActionGroups(e.Actions, e.Localized)
+	return w.result()
+}
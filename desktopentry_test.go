@@ -0,0 +1,89 @@
+package gendesk
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// Create and write the desktop entry for a package
+func writeEntry(t *testing.T, info PackageInfo, options Options) string {
+	entry, _, err := NewDesktopEntry(info, options)
+	if err != nil {
+		t.Fatalf("%s: %v", info.Pkgname, err)
+	}
+	var buf bytes.Buffer
+	if _, err := entry.WriteTo(&buf); err != nil {
+		t.Fatalf("%s: %v", info.Pkgname, err)
+	}
+	return buf.String()
+}
+
+// The .desktop files that gendesk writes should pass its own validate command,
+// without any errors or warnings
+func TestGeneratedEntriesValidate(t *testing.T) {
+	translations := make(Translations)
+	translations.set("Name", "de", "Schach")
+	translations.set("Comment", "de", "Ein Schachspiel")
+	packages := []PackageInfo{
+		{Pkgname: "foo"},
+		{Pkgname: "gnome-chess", Pkgdesc: "Play the classic two-player board game of chess"},
+		{
+			Pkgname:      "editor",
+			Pkgdesc:      "A text editor for programmers",
+			GenericName:  "Text Editor",
+			MimeTypes:    []string{"text/plain"},
+			ExecArgs:     "--new-window",
+			Actions:      []string{"new:New Window:editor --new-window"},
+			AddKeywords:  []string{"code"},
+			Optional:     map[string][]string{"StartupWMClass": {"Editor"}, "NoDisplay": {"false"}},
+			Translations: translations,
+		},
+		{Pkgname: "term", Pkgdesc: "Terminal emulator for GNOME", Categories: []string{"System", "TerminalEmulator"}},
+	}
+	for _, options := range []Options{{}, {Terminal: true, StartupNotify: true}, {WindowManager: true}} {
+		for _, info := range packages {
+			entry, _, err := NewDesktopEntry(info, options)
+			if err != nil {
+				t.Fatalf("%s: %v", info.Pkgname, err)
+			}
+			var buf bytes.Buffer
+			if _, err := entry.WriteTo(&buf); err != nil {
+				t.Fatalf("%s: %v", info.Pkgname, err)
+			}
+			for _, problem := range Validate(buf.Bytes()) {
+				t.Errorf("%s (%s): line %d: %s\n%s", info.Pkgname, entry.Type, problem.Line, problem.Message, buf.String())
+			}
+		}
+	}
+}
+
+// The additional keys can be given as PKGBUILD variables, and are written with the
+// value type of the key, in the order of OptionalKeys
+func TestOptionalKeys(t *testing.T) {
+	info := PackageInfo{Pkgname: "foo", Pkgdesc: "Foo viewer"}
+	info.SetVariable("_singlemainwindow", []string{"true"})
+	info.SetVariable("_onlyshowin", []string{"GNOME", "KDE;XFCE"})
+	info.SetVariable("_nodisplay", []string{"false"})
+	info.SetVariable("_tryexec", []string{"/usr/bin/foo"})
+	info.SetVariable("_keywords", []string{"view;foo files"})
+	info.SetVariable("_startupwmclass", []string{"Foo", "Viewer"})
+	info.SetVariable("_unknown", []string{"ignored"})
+	contents := writeEntry(t, info, Options{})
+	expected := "Keywords=view;foo files;\nTryExec=/usr/bin/foo\nNoDisplay=false\nOnlyShowIn=GNOME;KDE;XFCE;\nStartupWMClass=Foo Viewer\nSingleMainWindow=true\n"
+	if !strings.Contains(contents, expected) {
+		t.Errorf("expected the lines\n%s\nin\n%s", expected, contents)
+	}
+	if strings.Contains(contents, "ignored") {
+		t.Errorf("unknown variables should be ignored:\n%s", contents)
+	}
+
+	info.SetVariable("_hidden", []string{"yes"})
+	entry, _, err := NewDesktopEntry(info, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := entry.WriteTo(&bytes.Buffer{}); err == nil || !strings.Contains(err.Error(), "Hidden") {
+		t.Errorf("expected Hidden=yes to be rejected, got %v", err)
+	}
+}
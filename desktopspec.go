@@ -0,0 +1,257 @@
+package gendesk
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+)
+
+// Knowledge about the Desktop Entry Specification
+// https://specifications.freedesktop.org/desktop-entry-spec/latest/
+
+// Value types
+const (
+	valueString = iota
+	valueLocaleString
+	valueIconString
+	valueBoolean
+	valueNumeric
+)
+
+// A key in the [Desktop Entry] group
+type desktopKey struct {
+	valueType int
+	list      bool     // if the value is a list of strings, separated by ";"
+	types     []string // the values of Type where the key may be used, nil for all
+}
+
+var (
+	application = []string{"Application"}
+
+	desktopKeys = map[string]desktopKey{
+		"Type":                 {valueString, false, nil},
+		"Version":              {valueString, false, nil},
+		"Name":                 {valueLocaleString, false, nil},
+		"GenericName":          {valueLocaleString, false, nil},
+		"NoDisplay":            {valueBoolean, false, nil},
+		"Comment":              {valueLocaleString, false, nil},
+		"Icon":                 {valueIconString, false, nil},
+		"Hidden":               {valueBoolean, false, nil},
+		"OnlyShowIn":           {valueString, true, nil},
+		"NotShowIn":            {valueString, true, nil},
+		"DBusActivatable":      {valueBoolean, false, application},
+		"TryExec":              {valueString, false, application},
+		"Exec":                 {valueString, false, application},
+		"Path":                 {valueString, false, application},
+		"Terminal":             {valueBoolean, false, application},
+		"Actions":              {valueString, true, application},
+		"MimeType":             {valueString, true, application},
+		"Categories":           {valueString, true, application},
+		"Implements":           {valueString, true, nil},
+		"Keywords":             {valueLocaleString, true, application},
+		"StartupNotify":        {valueBoolean, false, application},
+		"StartupWMClass":       {valueString, false, application},
+		"URL":                  {valueString, false, []string{"Link"}},
+		"PrefersNonDefaultGPU": {valueBoolean, false, application},
+		"SingleMainWindow":     {valueBoolean, false, application},
+	}
+
+	// Keys in the [Desktop Action id] groups
+	actionKeys = map[string]desktopKey{
+		"Name": {valueLocaleString, false, nil},
+		"Icon": {valueIconString, false, nil},
+		"Exec": {valueString, false, nil},
+	}
+
+	// Keys that have been deprecated or were only ever used by KDE 3 and older
+	deprecatedKeys = map[string]bool{
+		"Encoding":        true,
+		"MiniIcon":        true,
+		"TerminalOptions": true,
+		"Protocols":       true,
+		"Extensions":      true,
+		"BinaryPattern":   true,
+		"MapNotify":       true,
+		"SwallowTitle":    true,
+		"SwallowExec":     true,
+		"SortOrder":       true,
+		"FilePattern":     true,
+		"Patterns":        true,
+		"DefaultApp":      true,
+		"Dev":             true,
+		"FSType":          true,
+		"MountPoint":      true,
+		"ReadOnly":        true,
+		"UnmountIcon":     true,
+	}
+
+	// Valid values for Type. XSession is not in the specification, but is used by
+	// display managers for the .desktop files that launch window managers.
+	desktopTypes           = map[string]bool{"Application": true, "Link": true, "Directory": true, "XSession": true}
+	deprecatedDesktopTypes = map[string]bool{"MimeType": true, "ServiceType": true, "Service": true, "FSDevice": true}
+
+	// Field codes for Exec, and the deprecated ones that should be removed
+	execFieldCodes           = "fFuUick%"
+	deprecatedExecFieldCodes = "dDnNvm"
+
+	// Characters that must be quoted in an argument in Exec
+	execReservedChars = " \t\n\"'\\><~|&;$*?#()`"
+)
+
+// Remove the escape sequences in a value. For lists, the elements are split on
+// ";" and returned separately, and "\;" can be used for a literal semicolon.
+func unescapeValue(value string, list bool) ([]string, error) {
+	var (
+		elements []string
+		current  bytes.Buffer
+	)
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		switch {
+		case c == '\\':
+			if i+1 >= len(value) {
+				return nil, errors.New("value ends with a backslash")
+			}
+			i++
+			switch value[i] {
+			case 's':
+				current.WriteByte(' ')
+			case 'n':
+				current.WriteByte('\n')
+			case 't':
+				current.WriteByte('\t')
+			case 'r':
+				current.WriteByte('\r')
+			case '\\':
+				current.WriteByte('\\')
+			case ';':
+				if !list {
+					return nil, errors.New("\\; is only valid in lists")
+				}
+				current.WriteByte(';')
+			default:
+				return nil, errors.New("invalid escape sequence \\" + string(value[i]))
+			}
+		case c == ';' && list:
+			elements = append(elements, current.String())
+			current.Reset()
+		default:
+			current.WriteByte(c)
+		}
+	}
+	// The last element may or may not be terminated by ";"
+	if !list || current.Len() > 0 {
+		elements = append(elements, current.String())
+	}
+	return elements, nil
+}
+
+// Split an (unescaped) Exec value into arguments, following the quoting rules
+// of the specification. Field codes are kept as they are.
+func splitExec(exec string) ([]string, error) {
+	var (
+		args    []string
+		current bytes.Buffer
+		inArg   bool
+	)
+	for i := 0; i < len(exec); i++ {
+		c := exec[i]
+		switch {
+		case c == ' ' || c == '\t':
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		case c == '"':
+			inArg = true
+			closed := false
+			for i++; i < len(exec); i++ {
+				if exec[i] == '"' {
+					closed = true
+					break
+				}
+				if exec[i] == '\\' {
+					if i+1 >= len(exec) || !strings.ContainsRune("\"`$\\", rune(exec[i+1])) {
+						return nil, errors.New("a backslash in a quoted argument must be followed by \", `, $ or \\")
+					}
+					i++
+				} else if exec[i] == '%' {
+					if i+1 >= len(exec) || exec[i+1] != '%' {
+						return nil, errors.New("field codes can not be used in a quoted argument")
+					}
+					// Keep %% as it is, like outside of quotes
+					current.WriteByte('%')
+					i++
+				}
+				current.WriteByte(exec[i])
+			}
+			if !closed {
+				return nil, errors.New("unterminated quoted argument")
+			}
+		case strings.IndexByte(execReservedChars, c) != -1:
+			return nil, errors.New("the reserved character " + fmtChar(c) + " must be quoted")
+		default:
+			inArg = true
+			current.WriteByte(c)
+		}
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+	if len(args) == 0 {
+		return nil, errors.New("no program given")
+	}
+	return args, nil
+}
+
+// Return a printable representation of a character
+func fmtChar(c byte) string {
+	switch c {
+	case '\n':
+		return "\\n"
+	case '\t':
+		return "\\t"
+	}
+	return string(c)
+}
+
+// A Desktop Entry key that can be given as a --flag, as an _underscored PKGBUILD
+// variable or as an environment variable with the same name as the PKGBUILD variable
+type OptionalKey struct {
+	Key    string // the key in the .desktop file
+	Option string // the name of the flag, and of the variable without the "_"
+	Help   string
+}
+
+// Check if the value of the key is true or false
+func (k OptionalKey) IsBoolean() bool {
+	return desktopKeys[k.Key].valueType == valueBoolean
+}
+
+// The keys that only need to be written if they are given. The value types are
+// the ones found in desktopKeys.
+var OptionalKeys = []OptionalKey{
+	{"Keywords", "keywords", "Keywords for searching, separated by ; (generated if not given)"},
+	{"TryExec", "tryexec", "Executable that must be installed for the shortcut to be shown"},
+	{"Path", "path", "Working directory to run the application in"},
+	{"NoDisplay", "nodisplay", "Hide the shortcut from menus"},
+	{"Hidden", "hidden", "Treat the shortcut as deleted"},
+	{"OnlyShowIn", "onlyshowin", "Desktop environments to show the shortcut in, separated by ;"},
+	{"NotShowIn", "notshowin", "Desktop environments to not show the shortcut in, separated by ;"},
+	{"StartupWMClass", "startupwmclass", "The WM class of the main window"},
+	{"DBusActivatable", "dbusactivatable", "The application can be started with D-Bus"},
+	{"PrefersNonDefaultGPU", "prefersnondefaultgpu", "Run the application on a discrete GPU"},
+	{"SingleMainWindow", "singlemainwindow", "The application only has one main window"},
+	{"Implements", "implements", "D-Bus interfaces that are implemented, separated by ;"},
+}
+
+// Find the optional key for a PKGBUILD or environment variable, like _tryexec
+func optionalKeyForVariable(variable string) (OptionalKey, bool) {
+	for _, k := range OptionalKeys {
+		if "_"+k.Option == variable {
+			return k, true
+		}
+	}
+	return OptionalKey{}, false
+}
@@ -0,0 +1,339 @@
+package gendesk
+
+import (
+	"bytes"
+	"errors"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Writes groups and key/value pairs to a .desktop file, escaping the values
+// according to the Desktop Entry Specification. The first error that is
+// encountered is kept, and the remaining writes are then ignored.
+type desktopWriter struct {
+	buf  *bytes.Buffer
+	keys map[string]bool // the keys that have been written to the current group
+	err  error
+}
+
+func newDesktopWriter() *desktopWriter {
+	var buf []byte
+	return &desktopWriter{buf: bytes.NewBuffer(buf)}
+}
+
+// Start a new group, like [Desktop Entry]
+func (w *desktopWriter) group(name string) {
+	if w.err != nil {
+		return
+	}
+	if name == "" || strings.ContainsAny(name, "[]\n") {
+		w.err = errors.New("invalid group name: " + name)
+		return
+	}
+	if w.buf.Len() > 0 {
+		w.buf.WriteString("\n")
+	}
+	w.buf.WriteString("[" + name + "]\n")
+	w.keys = make(map[string]bool)
+}
+
+// Write a key and an already escaped value
+func (w *desktopWriter) writeRaw(key, value string) {
+	if w.err != nil {
+		return
+	}
+	if w.keys == nil {
+		w.err = errors.New("the key " + key + " must be placed in a group")
+		return
+	}
+	if !keyNameRegexp.MatchString(key) {
+		w.err = errors.New("invalid key name: " + key)
+		return
+	}
+	if w.keys[key] {
+		w.err = errors.New("the key " + key + " is given more than once")
+		return
+	}
+	w.keys[key] = true
+	w.buf.WriteString(key + "=" + value + "\n")
+}
+
+// Write a string value
+func (w *desktopWriter) writeString(key, value string) {
+	escaped, err := escapeValue(value)
+	if err != nil {
+		w.fail(key, err)
+		return
+	}
+	w.writeRaw(key, escaped)
+}
+
+// Write a boolean value
+func (w *desktopWriter) writeBool(key string, value bool) {
+	b2s := map[bool]string{false: "false", true: "true"}
+	w.writeRaw(key, b2s[value])
+}
+
+// Write a list of strings, separated and terminated by ";"
+func (w *desktopWriter) writeList(key string, values []string) {
+	escaped, err := escapeList(values)
+	if err != nil {
+		w.fail(key, err)
+		return
+	}
+	w.writeRaw(key, escaped)
+}
+
+// Write a command line, quoting the arguments as needed.
+// The command is split into arguments the way a shell would do it.
+func (w *desktopWriter) writeExec(key, command string) {
+	exec, err := quoteExec(command)
+	if err == nil {
+		exec, err = escapeValue(exec)
+	}
+	if err != nil {
+		w.fail(key, err)
+		return
+	}
+	w.writeRaw(key, exec)
+}
+
+// Write only the program of a command, without the arguments, like TryExec=foo for "foo --bar %F"
+func (w *desktopWriter) writeProgram(key, command string) {
+	args, err := splitCommand(command)
+	if err != nil {
+		w.fail(key, err)
+		return
+	}
+	w.writeString(key, args[0])
+}
+
+// Write custom lines, as given with --custom or _custom. The lines must be groups or
+// key=value pairs with already escaped values, and can not replace any written keys.
+func (w *desktopWriter) writeCustom(custom string) {
+	for _, line := range strings.Split(custom, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "" || strings.HasPrefix(line, "#"):
+			continue
+		case strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]"):
+			w.group(line[1 : len(line)-1])
+		case strings.Contains(line, "="):
+			pos := strings.Index(line, "=")
+			key, value := strings.TrimSpace(line[:pos]), strings.TrimSpace(line[pos+1:])
+			if err := checkRepresentable(value); err != nil {
+				w.fail(key, err)
+				return
+			}
+			if _, err := unescapeValue(value, true); err != nil {
+				w.fail(key, err)
+				return
+			}
+			w.writeRaw(key, value)
+		default:
+			if w.err == nil {
+				w.err = errors.New("invalid custom line, expected a key=value pair or a group: " + line)
+			}
+		}
+	}
+}
+
+// Keep the first error, with the key it is about
+func (w *desktopWriter) fail(key string, err error) {
+	if w.err == nil {
+		w.err = errors.New("the value for " + key + " can not be written: " + err.Error())
+	}
+}
+
+// Return the contents that have been written, or the first error
+func (w *desktopWriter) result() (*bytes.Buffer, error) {
+	if w.err != nil {
+		return nil, w.err
+	}
+	return w.buf, nil
+}
+
+// Check if a string can be represented in a .desktop file at all
+func checkRepresentable(value string) error {
+	if !utf8.ValidString(value) {
+		return errors.New("it is not valid UTF-8")
+	}
+	for _, r := range value {
+		if r != '\n' && r != '\t' && r != '\r' && isControl(r) {
+			return errors.New("it contains control characters")
+		}
+	}
+	return nil
+}
+
+// Escape a string value with \s, \n, \t, \r and \\
+func escapeValue(value string) (string, error) {
+	if err := checkRepresentable(value); err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	for i, r := range value {
+		switch r {
+		case '\\':
+			buf.WriteString(`\\`)
+		case '\n':
+			buf.WriteString(`\n`)
+		case '\t':
+			buf.WriteString(`\t`)
+		case '\r':
+			buf.WriteString(`\r`)
+		case ' ':
+			// Leading spaces would be removed when reading the file
+			if i == 0 {
+				buf.WriteString(`\s`)
+			} else {
+				buf.WriteRune(r)
+			}
+		default:
+			buf.WriteRune(r)
+		}
+	}
+	return buf.String(), nil
+}
+
+// Escape a list of values, using "\;" for semicolons within the elements
+func escapeList(values []string) (string, error) {
+	var buf bytes.Buffer
+	for _, value := range values {
+		if value == "" {
+			continue
+		}
+		escaped, err := escapeValue(value)
+		if err != nil {
+			return "", err
+		}
+		buf.WriteString(strings.Replace(escaped, ";", `\;`, -1) + ";")
+	}
+	return buf.String(), nil
+}
+
+// Split a command line into arguments, the way a shell would do it, but without any
+// expansion of variables. Returns the arguments quoted by the rules for Exec.
+func quoteExec(command string) (string, error) {
+	args, err := splitCommand(command)
+	if err != nil {
+		return "", err
+	}
+	if problems, _ := checkFieldCodes(args); len(problems) > 0 {
+		return "", errors.New(problems[0])
+	}
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		q, err := quoteExecArg(arg)
+		if err != nil {
+			return "", err
+		}
+		quoted[i] = q
+	}
+	return strings.Join(quoted, " "), nil
+}
+
+// Split a command line into arguments, the way a shell would do it, but without any
+// expansion of variables
+func splitCommand(command string) ([]string, error) {
+	var (
+		args    []string
+		current bytes.Buffer
+		inArg   bool
+	)
+	for i := 0; i < len(command); i++ {
+		c := command[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n':
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		case c == '\'':
+			inArg = true
+			end := strings.IndexByte(command[i+1:], '\'')
+			if end == -1 {
+				return nil, errors.New("unterminated ' in the command")
+			}
+			current.WriteString(command[i+1 : i+1+end])
+			i += end + 1
+		case c == '"':
+			inArg = true
+			closed := false
+			for i++; i < len(command); i++ {
+				if command[i] == '"' {
+					closed = true
+					break
+				}
+				if command[i] == '\\' && i+1 < len(command) && strings.IndexByte("\"`$\\", command[i+1]) != -1 {
+					i++
+				}
+				current.WriteByte(command[i])
+			}
+			if !closed {
+				return nil, errors.New("unterminated \" in the command")
+			}
+		case c == '\\' && i+1 < len(command):
+			inArg = true
+			i++
+			current.WriteByte(command[i])
+		default:
+			inArg = true
+			current.WriteByte(c)
+		}
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+	if len(args) == 0 {
+		return nil, errors.New("the command is empty")
+	}
+	return args, nil
+}
+
+// Quote an argument for Exec, if it contains any reserved characters
+func quoteExecArg(arg string) (string, error) {
+	if arg != "" && strings.IndexAny(arg, execReservedChars) == -1 {
+		return arg, nil
+	}
+	// Field codes can not be used within quoted arguments
+	for i := 0; i+1 < len(arg); i++ {
+		if arg[i] == '%' {
+			if arg[i+1] != '%' {
+				return "", errors.New("the argument " + arg + " needs quoting and can not contain a field code")
+			}
+			i++
+		}
+	}
+	var buf bytes.Buffer
+	buf.WriteByte('"')
+	for i := 0; i < len(arg); i++ {
+		if strings.IndexByte("\"`$\\", arg[i]) != -1 {
+			buf.WriteByte('\\')
+		}
+		buf.WriteByte(arg[i])
+	}
+	buf.WriteByte('"')
+	return buf.String(), nil
+}
+
+// Write a value according to the type of the key, as given in desktopKeys.
+// Lists may be given as several values, or as values separated by ";".
+func (w *desktopWriter) writeValue(key string, values []string) {
+	info := desktopKeys[key]
+	switch {
+	case info.valueType == valueBoolean:
+		b, err := strconv.ParseBool(strings.Join(values, ""))
+		if err != nil {
+			w.fail(key, errors.New("expected true or false"))
+			return
+		}
+		w.writeBool(key, b)
+	case info.list:
+		w.writeList(key, splitList(values...))
+	default:
+		w.writeString(key, strings.Join(values, " "))
+	}
+}
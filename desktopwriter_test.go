@@ -0,0 +1,113 @@
+package gendesk
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Values are escaped as described in the specification, and read back the same
+func TestEscapeValue(t *testing.T) {
+	tests := []struct {
+		value, escaped string
+	}{
+		{"plain text", "plain text"},
+		{" leading space", `\sleading space`},
+		{"two\nlines", `two\nlines`},
+		{"tab\tand\rreturn", `tab\tand\rreturn`},
+		{`C:\path`, `C:\\path`},
+		{"semi;colon", "semi;colon"},
+	}
+	for _, test := range tests {
+		escaped, err := escapeValue(test.value)
+		if err != nil || escaped != test.escaped {
+			t.Errorf("escapeValue(%q) = %q, %v, expected %q", test.value, escaped, err, test.escaped)
+			continue
+		}
+		if unescaped, err := unescapeValue(escaped, false); err != nil || unescaped[0] != test.value {
+			t.Errorf("unescapeValue(%q) = %q, %v, expected %q", escaped, unescaped, err, test.value)
+		}
+	}
+	for _, value := range []string{"bell\a", "invalid \xff UTF-8"} {
+		if _, err := escapeValue(value); err == nil {
+			t.Errorf("escapeValue(%q): expected an error", value)
+		}
+	}
+}
+
+// Semicolons within list elements are escaped as \;
+func TestEscapeList(t *testing.T) {
+	values := []string{"a;b", "", "c d", `e\`}
+	escaped, err := escapeList(values)
+	if err != nil || escaped != `a\;b;c d;e\\;` {
+		t.Fatalf("escapeList(%q) = %q, %v", values, escaped, err)
+	}
+	unescaped, err := unescapeValue(escaped, true)
+	if expected := []string{"a;b", "c d", `e\`}; err != nil || !reflect.DeepEqual(unescaped, expected) {
+		t.Errorf("unescapeValue(%q) = %q, %v, expected %q", escaped, unescaped, err, expected)
+	}
+}
+
+// Commands are split the way a shell would do it, and the arguments are quoted by the
+// rules for Exec
+func TestQuoteExec(t *testing.T) {
+	tests := []struct {
+		command, exec string
+		problem       string // a part of the expected error, or "" for none
+	}{
+		{"foo --bar %U", "foo --bar %U", ""},
+		{`foo "two words"`, `foo "two words"`, ""},
+		{`foo two\ words 'single quoted'`, `foo "two words" "single quoted"`, ""},
+		{`foo '$HOME' 'say "hi"' 'back\slash'`, `foo "\$HOME" "say \"hi\"" "back\\slash"`, ""},
+		{"foo 50%%", "foo 50%%", ""},
+		{"foo --size=100%", "", "incomplete field code"},
+		{`foo "a %f"`, "", "can not contain a field code"},
+		{"foo %f %U", "", "only contain one of"},
+		{"foo 'unterminated", "", "unterminated '"},
+		{`foo "unterminated`, "", `unterminated "`},
+		{"  ", "", "empty"},
+	}
+	for _, test := range tests {
+		exec, err := quoteExec(test.command)
+		switch {
+		case test.problem == "" && (err != nil || exec != test.exec):
+			t.Errorf("quoteExec(%q) = %q, %v, expected %q", test.command, exec, err, test.exec)
+		case test.problem != "" && (err == nil || !strings.Contains(err.Error(), test.problem)):
+			t.Errorf("quoteExec(%q): expected an error with %q, got %q, %v", test.command, test.problem, exec, err)
+		}
+		if err != nil {
+			continue
+		}
+		// The quoted arguments are read back as the original arguments
+		args, err := splitExec(exec)
+		if expected, _ := splitCommand(test.command); err != nil || !reflect.DeepEqual(args, expected) {
+			t.Errorf("splitExec(%q) = %q, %v, expected %q", exec, args, err, expected)
+		}
+	}
+}
+
+// Custom lines can add keys and groups, but not replace keys or break the file
+func TestWriteCustom(t *testing.T) {
+	tests := []struct {
+		custom  string
+		problem string
+	}{
+		{"X-Foo=bar\n\n[X-Group]\nKey=a\\;b;", ""},
+		{"Exec=evil", "given more than once"},
+		{"X-Foo=bad\\escape", "invalid escape sequence"},
+		{"not a key", "expected a key=value pair"},
+	}
+	for _, test := range tests {
+		w := newDesktopWriter()
+		w.group("Desktop Entry")
+		w.writeExec("Exec", "foo")
+		w.writeCustom(test.custom)
+		_, err := w.result()
+		switch {
+		case test.problem == "" && err != nil:
+			t.Errorf("%q: %v", test.custom, err)
+		case test.problem != "" && (err == nil || !strings.Contains(err.Error(), test.problem)):
+			t.Errorf("%q: expected an error with %q, got %v", test.custom, test.problem, err)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package gendesk
+
+import (
+	"strings"
+)
+
+// Check the field codes in the arguments of an Exec value, as split by splitExec.
+// Returns the problems that make the value invalid, and the uses of deprecated field codes.
+func checkFieldCodes(args []string) (problems []string, deprecated []string) {
+	fileCodes := 0
+	for i, arg := range args {
+		for j := 0; j < len(arg); j++ {
+			if arg[j] != '%' {
+				continue
+			}
+			if j+1 >= len(arg) {
+				problems = append(problems, "Exec ends with an incomplete field code")
+				break
+			}
+			j++
+			code := arg[j]
+			switch {
+			case strings.IndexByte(deprecatedExecFieldCodes, code) != -1:
+				deprecated = append(deprecated, "the field code %"+string(code)+" in Exec is deprecated")
+			case strings.IndexByte(execFieldCodes, code) == -1:
+				problems = append(problems, "invalid field code %"+string(code)+" in Exec")
+			case strings.IndexByte("fFuU", code) != -1:
+				fileCodes++
+			}
+			// %F and %U expand to several arguments, and %i to --icon and the icon name
+			if strings.IndexByte("FUi", code) != -1 && arg != "%"+string(code) {
+				problems = append(problems, "the field code %"+string(code)+" must be used as a separate argument in Exec")
+			}
+			if i == 0 && code != '%' {
+				problems = append(problems, "the program in Exec can not be a field code")
+			}
+		}
+	}
+	if fileCodes > 1 {
+		problems = append(problems, "Exec may only contain one of the field codes %f, %F, %u and %U")
+	}
+	return problems, deprecated
+}
+
+// Check if a command contains one of the field codes for files or URLs
+func hasFileFieldCode(command string) bool {
+	for i := 0; i+1 < len(command); i++ {
+		if command[i] == '%' {
+			if strings.IndexByte("fFuU", command[i+1]) != -1 {
+				return true
+			}
+			// Skip %%
+			i++
+		}
+	}
+	return false
+}
+
+// Add the arguments given with --exec-args or _exec_args to the command. If the
+// application handles MIME types and no file or URL field code is given, %F is
+// added, or %U if one of the types is a URL scheme, like x-scheme-handler/http.
+func execWithArgs(exec, args string, mimeTypes []string) string {
+	if args = strings.TrimSpace(args); args != "" {
+		exec += " " + args
+	}
+	if len(mimeTypes) == 0 || hasFileFieldCode(exec) {
+		return exec
+	}
+	for _, mimeType := range mimeTypes {
+		if strings.HasPrefix(mimeType, "x-scheme-handler/") {
+			return exec + " %U"
+		}
+	}
+	return exec + " %F"
+}
@@ -0,0 +1,57 @@
+package gendesk
+
+import (
+	"strings"
+	"testing"
+)
+
+// Field codes are checked as described in the specification
+func TestCheckFieldCodes(t *testing.T) {
+	tests := []struct {
+		args       []string
+		problem    string // a part of the expected problem, or "" for none
+		deprecated bool
+	}{
+		{[]string{"foo", "%U"}, "", false},
+		{[]string{"foo", "--file=%f", "%i", "%c", "50%%"}, "", false},
+		{[]string{"foo", "%F", "%u"}, "only contain one of", false},
+		{[]string{"foo", "--files=%F"}, "separate argument", false},
+		{[]string{"foo", "%x"}, "invalid field code %x", false},
+		{[]string{"foo", "100%"}, "incomplete field code", false},
+		{[]string{"%f", "foo"}, "can not be a field code", false},
+		{[]string{"foo", "%d"}, "", true},
+	}
+	for _, test := range tests {
+		problems, deprecated := checkFieldCodes(test.args)
+		switch {
+		case test.problem == "" && len(problems) > 0:
+			t.Errorf("%q: unexpected problems %q", test.args, problems)
+		case test.problem != "" && (len(problems) == 0 || !strings.Contains(strings.Join(problems, "\n"), test.problem)):
+			t.Errorf("%q: expected a problem with %q, got %q", test.args, test.problem, problems)
+		}
+		if (len(deprecated) > 0) != test.deprecated {
+			t.Errorf("%q: deprecated field codes %q", test.args, deprecated)
+		}
+	}
+}
+
+// %F or %U is added to the command when the application handles MIME types
+func TestExecWithArgs(t *testing.T) {
+	tests := []struct {
+		exec, args string
+		mimeTypes  []string
+		expected   string
+	}{
+		{"foo", "", nil, "foo"},
+		{"foo", " --bar ", nil, "foo --bar"},
+		{"foo", "", []string{"image/png"}, "foo %F"},
+		{"foo", "--new", []string{"text/html", "x-scheme-handler/http"}, "foo --new %U"},
+		{"foo", "--open %u", []string{"image/png"}, "foo --open %u"},
+		{"foo", "--size 100%%f", []string{"image/png"}, "foo --size 100%%f %F"},
+	}
+	for _, test := range tests {
+		if exec := execWithArgs(test.exec, test.args, test.mimeTypes); exec != test.expected {
+			t.Errorf("execWithArgs(%q, %q, %q) = %q, expected %q", test.exec, test.args, test.mimeTypes, exec, test.expected)
+		}
+	}
+}
@@ -0,0 +1,271 @@
+package gendesk
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// An entry in a .pot file
+type potEntry struct {
+	msgid      string
+	comments   []string // extracted comments, like the name of the key
+	references []string // where the message is used, like "foo.desktop"
+}
+
+// The messages to be translated, in the order they were added
+type PotCatalog struct {
+	entries []*potEntry
+	index   map[string]*potEntry
+}
+
+func NewPotCatalog() *PotCatalog {
+	return &PotCatalog{index: make(map[string]*potEntry)}
+}
+
+// Add a message, or add the comment and the reference to an existing one
+func (c *PotCatalog) add(msgid, comment, reference string) {
+	if msgid == "" {
+		return
+	}
+	entry, found := c.index[msgid]
+	if !found {
+		entry = &potEntry{msgid: msgid}
+		c.index[msgid] = entry
+		c.entries = append(c.entries, entry)
+	}
+	if !containsString(entry.comments, comment) {
+		entry.comments = append(entry.comments, comment)
+	}
+	if !containsString(entry.references, reference) {
+		entry.references = append(entry.references, reference)
+	}
+}
+
+// Add the translatable fields of a desktop entry to the catalog. The entry is
+// referred to as pkgname.desktop.
+func (c *PotCatalog) AddEntry(pkgname string, entry *DesktopEntry) {
+	reference := pkgname + ".desktop"
+	c.add(entry.Name, "Name", reference)
+	c.add(entry.GenericName, "GenericName", reference)
+	c.add(entry.Comment, "Comment", reference)
+	if keywords := entry.Optional["Keywords"]; len(keywords) > 0 {
+		// Translators should keep the list separated by ";"
+		c.add(strings.Join(keywords, ";")+";", "Keywords (separated by ;)", reference)
+	}
+	for _, action := range entry.Actions {
+		c.add(action.Name, "Name of the "+action.ID+" action", reference)
+	}
+}
+
+// Write the contents of the .pot file
+func (c *PotCatalog) WriteTo(w io.Writer) (int64, error) {
+	var buf bytes.Buffer
+	buf.WriteString("msgid \"\"\nmsgstr \"\"\n")
+	buf.WriteString("\"Content-Type: text/plain; charset=UTF-8\\n\"\n")
+	buf.WriteString("\"Content-Transfer-Encoding: 8bit\\n\"\n")
+	for _, entry := range c.entries {
+		buf.WriteString("\n")
+		for _, comment := range entry.comments {
+			buf.WriteString("#. " + comment + "\n")
+		}
+		buf.WriteString("#: " + strings.Join(entry.references, " ") + "\n")
+		buf.WriteString("msgid " + poQuote(entry.msgid) + "\n")
+		buf.WriteString("msgstr \"\"\n")
+	}
+	return buf.WriteTo(w)
+}
+
+// Quote a string for a .po file
+func poQuote(s string) string {
+	var buf bytes.Buffer
+	buf.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			buf.WriteString(`\"`)
+		case '\\':
+			buf.WriteString(`\\`)
+		case '\n':
+			buf.WriteString(`\n`)
+		case '\t':
+			buf.WriteString(`\t`)
+		default:
+			buf.WriteRune(r)
+		}
+	}
+	buf.WriteByte('"')
+	return buf.String()
+}
+
+// Parse the contents of a .po file and return the translated messages, as msgid -> msgstr.
+// Fuzzy, obsolete and untranslated messages, as well as plural forms, are skipped.
+// The context of a message, if any, is not used.
+func parsePO(contents string) (map[string]string, error) {
+	messages := make(map[string]string)
+	var (
+		msgid, msgstr, msgctxt *bytes.Buffer
+		current                *bytes.Buffer // the string that continuation lines are added to
+		fuzzy, plural          bool
+	)
+	// Store the message that has been read so far, if any
+	flush := func() {
+		if msgid != nil && msgstr != nil && !fuzzy && !plural && msgid.Len() > 0 && msgstr.Len() > 0 {
+			messages[msgid.String()] = msgstr.String()
+		}
+		msgid, msgstr, msgctxt, current = nil, nil, nil, nil
+		fuzzy, plural = false, false
+	}
+	for i, line := range strings.Split(contents, "\n") {
+		line = strings.TrimSpace(line)
+		var keyword, rest string
+		switch {
+		case line == "":
+			flush()
+			continue
+		case strings.HasPrefix(line, "#,"):
+			if msgid != nil {
+				flush()
+			}
+			fuzzy = fuzzy || strings.Contains(line, "fuzzy")
+			continue
+		case strings.HasPrefix(line, "#"):
+			// Comments and obsolete messages
+			continue
+		case strings.HasPrefix(line, "\""):
+			if current == nil {
+				return nil, errors.New("line " + strconv.Itoa(i+1) + ": unexpected string")
+			}
+			rest = line
+		default:
+			pos := strings.IndexAny(line, " \t")
+			if pos == -1 {
+				return nil, errors.New("line " + strconv.Itoa(i+1) + ": expected a keyword and a string")
+			}
+			keyword, rest = line[:pos], strings.TrimSpace(line[pos+1:])
+		}
+		s, err := poUnquote(rest)
+		if err != nil {
+			return nil, errors.New("line " + strconv.Itoa(i+1) + ": " + err.Error())
+		}
+		switch keyword {
+		case "":
+			current.WriteString(s)
+			continue
+		case "msgctxt":
+			if msgid != nil {
+				flush()
+			}
+			msgctxt = new(bytes.Buffer)
+			current = msgctxt
+		case "msgid":
+			if msgid != nil && msgstr != nil {
+				flush()
+			}
+			msgid = new(bytes.Buffer)
+			current = msgid
+		case "msgid_plural":
+			plural = true
+			current = new(bytes.Buffer)
+		case "msgstr":
+			msgstr = new(bytes.Buffer)
+			current = msgstr
+		default:
+			if strings.HasPrefix(keyword, "msgstr[") {
+				plural = true
+				current = new(bytes.Buffer)
+				break
+			}
+			return nil, errors.New("line " + strconv.Itoa(i+1) + ": unknown keyword " + keyword)
+		}
+		current.WriteString(s)
+	}
+	flush()
+	return messages, nil
+}
+
+// Remove the quotes and the escape sequences from a string in a .po file
+func poUnquote(s string) (string, error) {
+	if len(s) < 2 || s[0] != '"' || s[len(s)-1] != '"' {
+		return "", errors.New("expected a quoted string")
+	}
+	s = s[1 : len(s)-1]
+	var buf bytes.Buffer
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			buf.WriteByte(s[i])
+			continue
+		}
+		i++
+		if i >= len(s) {
+			return "", errors.New("the string ends with a backslash")
+		}
+		switch s[i] {
+		case 'n':
+			buf.WriteByte('\n')
+		case 't':
+			buf.WriteByte('\t')
+		case 'r':
+			buf.WriteByte('\r')
+		default:
+			buf.WriteByte(s[i])
+		}
+	}
+	return buf.String(), nil
+}
+
+// Read all the .po files in a directory. The locale is the name of the file,
+// like de.po or pt_BR.po. Returns the messages as locale -> msgid -> msgstr.
+func readPODir(dir string) (map[string]map[string]string, error) {
+	filenames, err := filepath.Glob(filepath.Join(dir, "*.po"))
+	if err != nil {
+		return nil, err
+	}
+	if len(filenames) == 0 {
+		return nil, errors.New("no .po files found in " + dir)
+	}
+	sort.Strings(filenames)
+	catalogs := make(map[string]map[string]string)
+	for _, filename := range filenames {
+		locale := strings.TrimSuffix(filepath.Base(filename), ".po")
+		if !localeRegexp.MatchString(locale) {
+			return nil, errors.New(filename + ": the filename is not a locale, like de.po or pt_BR.po")
+		}
+		filedata, err := ioutil.ReadFile(filename)
+		if err != nil {
+			return nil, err
+		}
+		messages, err := parsePO(string(filedata))
+		if err != nil {
+			return nil, errors.New(filename + ": " + err.Error())
+		}
+		catalogs[locale] = messages
+	}
+	return catalogs, nil
+}
+
+// Look up the translatable fields of a desktop entry in the .po catalogs, and return
+// the localized values. Action names are stored under "Desktop Action id".
+func translationsFromPO(catalogs map[string]map[string]string, name, genericName, comment string, keywords []string, actions []Action) Translations {
+	localized := make(Translations)
+	sources := map[string]string{"Name": name, "GenericName": genericName, "Comment": comment}
+	if len(keywords) > 0 {
+		sources["Keywords"] = strings.Join(keywords, ";") + ";"
+	}
+	for _, action := range actions {
+		sources["Desktop Action "+action.ID] = action.Name
+	}
+	for locale, messages := range catalogs {
+		for key, msgid := range sources {
+			if msgstr, found := messages[msgid]; found && msgid != "" {
+				localized.set(key, locale, msgstr)
+			}
+		}
+	}
+	return localized
+}
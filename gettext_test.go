@@ -0,0 +1,138 @@
+package gendesk
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Only translated messages that are not fuzzy or plural forms are read from .po files
+func TestParsePO(t *testing.T) {
+	contents := `# German translation
+msgid ""
+msgstr ""
+"Content-Type: text/plain; charset=UTF-8\n"
+
+#. Name
+#: foo.desktop
+msgid "Image Viewer"
+msgstr "Bildbetrachter"
+
+msgid "A "
+"multi-line \"message\""
+msgstr "Eine mehrzeilige\t"
+"\"Nachricht\""
+
+#, fuzzy
+msgid "Fuzzy"
+msgstr "Unscharf"
+
+msgid "Untranslated"
+msgstr ""
+
+msgid "file"
+msgid_plural "files"
+msgstr[0] "Datei"
+msgstr[1] "Dateien"
+
+msgctxt "menu"
+msgid "Open"
+msgstr "Öffnen"
+
+#~ msgid "Obsolete"
+#~ msgstr "Veraltet"
+`
+	messages, err := parsePO(contents)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{
+		"Image Viewer":             "Bildbetrachter",
+		"A multi-line \"message\"": "Eine mehrzeilige\t\"Nachricht\"",
+		"Open":                     "Öffnen",
+	}
+	if !reflect.DeepEqual(messages, expected) {
+		t.Errorf("got %q, expected %q", messages, expected)
+	}
+	for _, invalid := range []string{"msgid \"unterminated", "\"no keyword\"", "msgfoo \"bar\"", "msgid \"ends with \\\""} {
+		if _, err := parsePO(invalid); err == nil {
+			t.Errorf("%q: expected an error", invalid)
+		}
+	}
+}
+
+// Messages that are used by several entries are only added once to the .pot file,
+// and the written .pot file can be read back after translating it
+func TestPotCatalog(t *testing.T) {
+	catalog := NewPotCatalog()
+	catalog.AddEntry("foo", &DesktopEntry{
+		Name:     "Foo",
+		Comment:  "View \"images\"",
+		Optional: map[string][]string{"Keywords": {"image", "view"}},
+		Actions:  []Action{{ID: "new", Name: "New Window"}},
+	})
+	catalog.AddEntry("bar", &DesktopEntry{Name: "Bar", Comment: "View \"images\""})
+	var buf bytes.Buffer
+	if _, err := catalog.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	pot := buf.String()
+	for _, expected := range []string{
+		"#. Comment\n#: foo.desktop bar.desktop\nmsgid \"View \\\"images\\\"\"\nmsgstr \"\"\n",
+		"#. Keywords (separated by ;)\n#: foo.desktop\nmsgid \"image;view;\"\n",
+		"#. Name of the new action\n#: foo.desktop\nmsgid \"New Window\"\n",
+	} {
+		if !strings.Contains(pot, expected) {
+			t.Errorf("expected\n%s\nin\n%s", expected, pot)
+		}
+	}
+	if strings.Count(pot, "msgid \"View") != 1 {
+		t.Errorf("expected the comment to be added once:\n%s", pot)
+	}
+	messages, err := parsePO(strings.Replace(pot, "msgid \"Foo\"\nmsgstr \"\"", "msgid \"Foo\"\nmsgstr \"Fu\"", 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := map[string]string{"Foo": "Fu"}; !reflect.DeepEqual(messages, expected) {
+		t.Errorf("got %q, expected %q", messages, expected)
+	}
+}
+
+// The translations in a directory of .po files are written as localized keys
+func TestPODir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gendesk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	po := "msgid \"Image viewer\"\nmsgstr \"Bildbetrachter\"\n\nmsgid \"image;view;\"\nmsgstr \"Bild;ansehen;\"\n\nmsgid \"New Window\"\nmsgstr \"Neues Fenster\"\n"
+	if err := ioutil.WriteFile(filepath.Join(dir, "de.po"), []byte(po), 0644); err != nil {
+		t.Fatal(err)
+	}
+	info := PackageInfo{Pkgname: "foo", Pkgdesc: "Image viewer", Dir: dir, PODir: ".", Actions: []string{"new:New Window:foo --new"}}
+	info.SetVariable("_keywords", []string{"image", "view"})
+	contents := writeEntry(t, info, Options{})
+	for _, expected := range []string{
+		"Comment=Image viewer\nComment[de]=Bildbetrachter\n",
+		"Keywords=image;view;\nKeywords[de]=Bild;ansehen;\n",
+		"Name=New Window\nName[de]=Neues Fenster\n",
+	} {
+		if !strings.Contains(contents, expected) {
+			t.Errorf("expected\n%s\nin\n%s", expected, contents)
+		}
+	}
+	if contents := writeEntry(t, info, Options{SkipPO: true}); strings.Contains(contents, "[de]") {
+		t.Errorf("expected no translations with SkipPO:\n%s", contents)
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "german.po"), []byte(po), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readPODir(dir); err == nil {
+		t.Error("expected german.po to be rejected, since it is not named after a locale")
+	}
+}
@@ -1,83 +1,488 @@
-package main
-
-// TODO: Use an external file to read the mappings from (possibly JSON)
-
-const (
-	// Decides the order of the keyword/category checks
-	// (try to order from the more specific/specialized categories to the more general)
-	model3d_kw = iota
-	multimedia_kw
-	graphics_kw
-	network_kw
-	email_kw
-	audiovideo_kw
-	office_kw
-	editor_kw
-	science_kw
-	vcs_kw
-	arcadegame_kw
-	actiongame_kw
-	adventuregame_kw
-	logicgame_kw
-	boardgame_kw
-	game_kw
-	programming_kw
-	system_kw
-	last_kw
+package gendesk
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
 )
 
-var (
-	keywordmap = map[int][]string{model3d_kw: []string{"rendering", "modeling", "modelling", "modeler", "render", "raytracing"},
-		multimedia_kw:    []string{"non-linear", "audio", "sound", "graphics", "demo"},
-		graphics_kw:      []string{"draw", "pixelart"},
-		network_kw:       []string{"network", "p2p", "browser"},
-		email_kw:         []string{"gmail"},
-		audiovideo_kw:    []string{"synth", "synthesizer", "ffmpeg"},
-		office_kw:        []string{"ebook", "e-book", "spreadsheet", "calculator", "processor", "documents"},
-		editor_kw:        []string{"editor"},
-		science_kw:       []string{"gps", "inspecting", "molecular", "mathematics"},
-		vcs_kw:           []string{"git"},
-		arcadegame_kw:    []string{"combat", "arcade", "racing", "fighting", "fight", "shooter"},
-		actiongame_kw:    []string{"shooter", "fps"},
-		adventuregame_kw: []string{"roguelike", "rpg"},
-		logicgame_kw:     []string{"puzzle"},
-		boardgame_kw:     []string{"board", "chess", "goban", "chessboard", "checkers"},
-		// "emulator" and "player" aren't always for games, but those cases will be
-		// picked up by one of the other categories first
-		game_kw:        []string{"game", "rts", "mmorpg", "emulator", "player"},
-		programming_kw: []string{"code", "ide", "programming", "develop", "compile", "interpret", "valgrind"},
-		system_kw:      []string{"sensor", "bus", "calibration", "usb", "file"},
-	}
-	categorymap = map[int]string{model3d_kw: "Application;Graphics;3DGraphics",
-		multimedia_kw:    "Application;Multimedia",
-		graphics_kw:      "Application;Graphics",
-		network_kw:       "Application;Network",
-		email_kw:         "Application;Network;Email",
-		audiovideo_kw:    "Application;AudioVideo",
-		office_kw:        "Application;Office",
-		editor_kw:        "Application;Development;TextEditor",
-		science_kw:       "Application;Science",
-		vcs_kw:           "Application;Development;RevisionControl",
-		arcadegame_kw:    "Application;Game;ArcadeGame",
-		actiongame_kw:    "Application;Game;ActionGame",
-		adventuregame_kw: "Application;Game;AdventureGame",
-		logicgame_kw:     "Application;Game;",
-		boardgame_kw:     "Application;Game;BoardGame",
-		game_kw:          "Application;Game",
-		programming_kw:   "Application;Development",
-		system_kw:        "Application;System",
+// The built-in rules for guessing categories. When the scores are equal, the category
+// that was found by the rule that comes first wins, so the rules for the more specific
+// categories come before the more general ones. Keywords are matched by their stems,
+// so "game" also matches "games" and "gaming". The words of a phrase that a rule has
+// found, like "terminal emulator", are not used by the later rules, so that "emulator"
+// does not also find Emulator. The rules can be changed and extended with a category
+// rules file.
+var defaultCategoryRules = []CategoryRule{
+	// Science and education
+	{Name: "astronomy", Keywords: []string{"astronomy", "astronomical", "planetarium", "telescope", "sky", "star chart"}, Categories: "Science;Astronomy"},
+	{Name: "biology", Keywords: []string{"biology", "bioinformatics", "genome", "dna", "protein"}, Categories: "Science;Biology"},
+	{Name: "chemistry", Keywords: []string{"chemistry", "chemical", "molecular", "molecule", "periodic table"}, Categories: "Science;Chemistry"},
+	{Name: "physics", Keywords: []string{"physics"}, Categories: "Science;Physics"},
+	{Name: "numericalanalysis", Keywords: []string{"numerical", "numerical analysis", "matlab"}, Categories: "Science;Math;NumericalAnalysis"},
+	{Name: "math", Keywords: []string{"math", "mathematics", "algebra", "geometry", "calculus", "computer algebra", "equation"}, Categories: "Science;Math"},
+	{Name: "datavisualization", Keywords: []string{"data visualization", "plotting", "plot"}, Categories: "Science;DataVisualization"},
+	{Name: "geoscience", Keywords: []string{"gis", "geographic information system", "geospatial", "earth science"}, Categories: "Science;Geoscience", Weight: 2},
+	{Name: "geology", Keywords: []string{"geology", "seismic", "mineralogy"}, Categories: "Science;Geology"},
+	{Name: "geography", Keywords: []string{"geography", "atlas", "globe"}, Categories: "Education;Geography"},
+	{Name: "economy", Keywords: []string{"economy", "economics", "econometric"}, Categories: "Science;Economy"},
+	{Name: "electricity", Keywords: []string{"electricity", "electrical"}, Categories: "Science;Electricity"},
+	{Name: "electronics", Keywords: []string{"electronics", "electronic design", "pcb", "schematic", "circuit board", "circuit simulator"}, Categories: "Science;Electronics"},
+	{Name: "engineering", Keywords: []string{"mechanical engineering", "civil engineering", "structural engineering", "cad", "computer-aided design", "finite element"}, Categories: "Science;Engineering", Weight: 2},
+	{Name: "robotics", Keywords: []string{"robotics", "robot"}, Categories: "Science;Robotics"},
+	{Name: "medicalsoftware", Keywords: []string{"medical", "medicine", "dicom", "healthcare", "clinical", "anatomy"}, Categories: "Science;MedicalSoftware"},
+	{Name: "imageprocessing", Keywords: []string{"image processing", "computer vision", "microscopy"}, Categories: "Science;ImageProcessing"},
+	{Name: "artificialintelligence", Keywords: []string{"artificial intelligence", "machine learning", "deep learning", "neural network"}, Categories: "Science;ArtificialIntelligence"},
+	{Name: "parallelcomputing", Keywords: []string{"parallel computing", "mpi", "openmp", "hpc"}, Categories: "Science;ComputerScience;ParallelComputing"},
+	{Name: "computerscience", Keywords: []string{"computer science", "algorithms", "automata"}, Categories: "Science;ComputerScience"},
+	{Name: "history", Keywords: []string{"history", "historical", "genealogy", "family tree"}, Categories: "Education;History"},
+	{Name: "humanities", Keywords: []string{"humanities", "philosophy", "linguistics"}, Categories: "Education;Humanities"},
+	{Name: "literature", Keywords: []string{"literature", "poetry", "poems", "novels"}, Categories: "Education;Literature"},
+	{Name: "languages", Keywords: []string{"vocabulary", "language learning", "learn languages", "foreign language", "flashcards"}, Categories: "Education;Languages"},
+	{Name: "spirituality", Keywords: []string{"bible", "spirituality", "religious", "prayer", "quran", "scripture"}, Categories: "Education;Spirituality"},
+	{Name: "sports", Keywords: []string{"fitness", "workout", "exercise"}, Categories: "Education;Sports"},
+	{Name: "construction", Keywords: []string{"construction", "architecture", "lego"}, Categories: "Education;Construction"},
+	{Name: "art", Keywords: []string{"art", "artwork", "museum"}, Categories: "Education;Art", Weight: 0.5},
+	{Name: "science", Keywords: []string{"science", "scientific", "research", "laboratory", "inspecting"}, Categories: "Science"},
+	{Name: "education", Keywords: []string{"education", "educational", "learning", "teaching", "teacher", "school", "students", "tutor", "quiz"}, Groups: []string{"*-education", "*-edu"}, Categories: "Education"},
+
+	// Development
+	{Name: "vcs", Keywords: []string{"git", "version control", "revision control", "subversion", "svn", "mercurial"}, Categories: "Development;RevisionControl"},
+	{Name: "debugger", Keywords: []string{"debugger", "debugging", "gdb"}, Categories: "Development;Debugger"},
+	{Name: "ide", Keywords: []string{"ide", "integrated development environment"}, Categories: "Development;IDE"},
+	{Name: "guidesigner", Keywords: []string{"gui designer", "ui designer", "interface designer", "gui builder"}, Categories: "Development;GUIDesigner"},
+	{Name: "profiling", Keywords: []string{"profiler", "profiling", "valgrind"}, Categories: "Development;Profiling"},
+	{Name: "building", Keywords: []string{"build system", "build tool", "makefile", "cmake"}, Categories: "Development;Building"},
+	{Name: "translation", Keywords: []string{"gettext", "translation", "translator", "localization"}, Categories: "Development;Translation"},
+	{Name: "webdevelopment", Keywords: []string{"web development", "web design", "html editor", "css"}, Categories: "Development;WebDevelopment"},
+	{Name: "documentation", Keywords: []string{"documentation", "api documentation", "documentation browser"}, Categories: "Development;Documentation"},
+	{Name: "engine", Keywords: []string{"engine", "sdk", "framework"}, Categories: "Development"},
+
+	// Office
+	{Name: "calendar", Keywords: []string{"calendar", "agenda", "scheduling"}, Categories: "Office;Calendar"},
+	{Name: "contactmanagement", Keywords: []string{"contacts", "address book"}, Categories: "Office;ContactManagement"},
+	{Name: "database", Keywords: []string{"database", "sql", "sqlite", "mysql", "postgresql"}, Categories: "Office;Database"},
+	{Name: "dictionary", Keywords: []string{"dictionary", "thesaurus"}, Categories: "Office;Dictionary"},
+	{Name: "finance", Keywords: []string{"finance", "financial", "accounting", "banking", "budget", "money", "bitcoin", "wallet"}, Categories: "Office;Finance"},
+	{Name: "flowchart", Keywords: []string{"flowchart", "diagram", "uml"}, Categories: "Office;FlowChart"},
+	{Name: "chart", Keywords: []string{"chart", "charting"}, Categories: "Office;Chart"},
+	{Name: "pda", Keywords: []string{"pda", "palm"}, Categories: "Office;PDA"},
+	{Name: "projectmanagement", Keywords: []string{"project management", "gantt"}, Categories: "Office;ProjectManagement"},
+	{Name: "presentation", Keywords: []string{"presentation", "slides", "slideshow"}, Categories: "Office;Presentation"},
+	{Name: "spreadsheet", Keywords: []string{"spreadsheet"}, Categories: "Office;Spreadsheet"},
+	{Name: "wordprocessor", Keywords: []string{"word processor", "word processing", "document processor"}, Categories: "Office;WordProcessor"},
+	{Name: "publishing", Keywords: []string{"publishing", "desktop publishing", "typesetting", "latex"}, Categories: "Office;Publishing"},
+
+	// Graphics
+	{Name: "model3d", Keywords: []string{"3d", "rendering", "modeling", "modelling", "modeler", "render", "raytracing"}, Categories: "Graphics;3DGraphics"},
+	{Name: "ocr", Keywords: []string{"ocr", "optical character recognition"}, Categories: "Graphics;Scanning;OCR"},
+	{Name: "scanning", Keywords: []string{"scanner", "scanning"}, Categories: "Graphics;Scanning"},
+	{Name: "photography", Keywords: []string{"photo", "photography", "camera", "raw", "raw image"}, Categories: "Graphics;Photography"},
+	{Name: "vectorgraphics", Keywords: []string{"vector", "vector graphics", "svg"}, Categories: "Graphics;2DGraphics;VectorGraphics"},
+	{Name: "rastergraphics", Keywords: []string{"paint", "painting", "pixelart", "raster", "bitmap", "image manipulation", "image editor"}, Categories: "Graphics;2DGraphics;RasterGraphics"},
+	{Name: "2dgraphics", Keywords: []string{"2d"}, Categories: "Graphics;2DGraphics"},
+	{Name: "viewer", Keywords: []string{"image viewer", "document viewer", "pdf viewer", "viewer"}, Categories: "Graphics;Viewer"},
+
+	// Audio and video
+	{Name: "midi", Keywords: []string{"midi"}, Categories: "AudioVideo;Audio;Midi"},
+	{Name: "mixer", Keywords: []string{"mixer", "volume"}, Categories: "AudioVideo;Audio;Mixer"},
+	{Name: "sequencer", Keywords: []string{"sequencer", "drum machine", "music tracker"}, Categories: "AudioVideo;Audio;Sequencer"},
+	{Name: "tuner", Keywords: []string{"tuner"}, Categories: "AudioVideo;Audio;Tuner"},
+	{Name: "tv", Keywords: []string{"tv", "television", "iptv", "dvb"}, Categories: "AudioVideo;Video;TV"},
+	{Name: "audiovideoediting", Keywords: []string{"non-linear", "video editor", "audio editor", "sound editor"}, Categories: "AudioVideo;AudioVideoEditing"},
+	{Name: "player", Keywords: []string{"media player", "music player", "video player", "audio player", "mp3 player", "dvd player"}, Categories: "AudioVideo;Player"},
+	{Name: "recorder", Keywords: []string{"recorder", "recording", "screencast"}, Categories: "AudioVideo;Recorder"},
+	{Name: "discburning", Keywords: []string{"disc burning", "cd burning", "dvd burning", "burner"}, Categories: "AudioVideo;DiscBurning"},
+	{Name: "music", Keywords: []string{"sheet music", "music notation", "music education", "ear training"}, Categories: "AudioVideo;Music"},
+
+	// Network
+	{Name: "email", Keywords: []string{"email", "e-mail", "mail", "mail client", "gmail", "imap"}, Categories: "Network;Email"},
+	{Name: "ircclient", Keywords: []string{"irc"}, Categories: "Network;IRCClient"},
+	{Name: "instantmessaging", Keywords: []string{"instant messaging", "messenger", "xmpp", "jabber"}, Categories: "Network;InstantMessaging"},
+	{Name: "chat", Keywords: []string{"chat", "matrix client", "voice chat"}, Categories: "Network;Chat"},
+	{Name: "feed", Keywords: []string{"rss", "feed", "podcast"}, Categories: "Network;Feed"},
+	{Name: "filetransfer", Keywords: []string{"ftp", "sftp", "file transfer", "download manager"}, Categories: "Network;FileTransfer"},
+	{Name: "hamradio", Keywords: []string{"ham radio", "amateur radio", "morse", "sdr"}, Categories: "Network;HamRadio"},
+	{Name: "news", Keywords: []string{"usenet", "nntp", "newsreader", "news reader"}, Categories: "Network;News"},
+	{Name: "p2p", Keywords: []string{"p2p", "peer-to-peer", "bittorrent", "torrent", "ed2k"}, Categories: "Network;P2P"},
+	{Name: "remoteaccess", Keywords: []string{"remote desktop", "remote access", "vnc", "rdp", "ssh"}, Categories: "Network;RemoteAccess"},
+	{Name: "telephony", Keywords: []string{"voip", "sip", "softphone", "telephony"}, Categories: "Network;Telephony"},
+	{Name: "videoconference", Keywords: []string{"video conference", "video call", "webinar"}, Categories: "Network;VideoConference"},
+	{Name: "webbrowser", Keywords: []string{"web browser", "internet browser", "www browser"}, Categories: "Network;WebBrowser"},
+	{Name: "dialup", Keywords: []string{"dialup", "dial-up", "ppp", "modem"}, Categories: "Network;Dialup"},
+
+	// System, settings and utilities
+	{Name: "filemanager", Keywords: []string{"file manager", "file browser", "file commander"}, Categories: "System;FileTools;FileManager", Weight: 2},
+	{Name: "terminalemulator", Keywords: []string{"terminal emulator"}, Categories: "System;TerminalEmulator", Weight: 2},
+	{Name: "filesystem", Keywords: []string{"filesystem", "file system", "partition", "disk", "mount"}, Categories: "System;Filesystem"},
+	{Name: "monitor", Keywords: []string{"monitor", "system monitor", "task manager", "processes", "process viewer"}, Categories: "System;Monitor"},
+	{Name: "security", Keywords: []string{"security", "firewall", "password", "encryption", "antivirus", "pgp"}, Categories: "System;Security"},
+	// Emulators for game consoles come first, since they are also found by the next rule
+	{Name: "gameemulator", Keywords: []string{"nintendo", "snes", "gamecube", "wii", "playstation", "psp", "sega", "dos games", "mame", "arcade machine"}, Categories: "Game;Emulator"},
+	{Name: "emulator", Keywords: []string{"emulator", "emulation"}, Categories: "System;Emulator"},
+	{Name: "printing", Keywords: []string{"printer", "printing", "cups"}, Categories: "Settings;HardwareSettings;Printing"},
+	{Name: "hardwaresettings", Keywords: []string{"driver", "bluetooth", "tablet", "calibration", "hardware settings"}, Categories: "Settings;HardwareSettings"},
+	{Name: "desktopsettings", Keywords: []string{"theme switcher", "gtk theme", "icon theme", "desktop theme", "wallpaper", "appearance"}, Categories: "Settings;DesktopSettings"},
+	{Name: "packagemanager", Keywords: []string{"package manager", "pacman", "aur", "apt", "flatpak"}, Categories: "Settings;PackageManager"},
+	{Name: "accessibility", Keywords: []string{"accessibility", "screen reader", "magnifier", "on-screen keyboard", "impaired"}, Categories: "Utility;Accessibility"},
+	{Name: "archiving", Keywords: []string{"archive", "archiver", "backup"}, Categories: "Utility;Archiving"},
+	{Name: "compression", Keywords: []string{"compression", "compress", "zip", "7z", "rar"}, Categories: "Utility;Compression"},
+	{Name: "filetools", Keywords: []string{"file search", "file renamer", "rename files", "duplicate files"}, Categories: "Utility;FileTools"},
+	{Name: "calculator", Keywords: []string{"calculator", "scientific calculator"}, Categories: "Utility;Calculator"},
+	{Name: "clock", Keywords: []string{"clock", "stopwatch", "alarm clock", "countdown"}, Categories: "Utility;Clock"},
+	{Name: "maps", Keywords: []string{"maps", "openstreetmap", "navigation", "gps"}, Categories: "Utility;Maps"},
+	{Name: "texttools", Keywords: []string{"text tools", "text processing", "regular expression", "character map", "charmap"}, Categories: "Utility;TextTools"},
+	{Name: "telephonytools", Keywords: []string{"sms", "smartphone", "mobile phone", "phone manager"}, Categories: "Utility;TelephonyTools"},
+	{Name: "helpbrowser", Keywords: []string{"help browser", "man pages", "manual pages", "user manual"}, Categories: "Utility;Documentation"},
+	// Editors for something specific, like a level editor, belong in the category of that
+	{Name: "editor", Keywords: []string{"editor", "text editor"}, Categories: "Utility;TextEditor", Weight: 0.5},
+
+	// Games
+	{Name: "shooter", Keywords: []string{"shooter", "fps", "first-person shooter"}, Categories: "Game;Shooter"},
+	{Name: "arcadegame", Keywords: []string{"combat", "arcade", "racing", "fighting", "fight", "pinball"}, Categories: "Game;ArcadeGame"},
+	{Name: "actiongame", Keywords: []string{"action game", "platform game", "jump and run", "jump n run", "sidescroller", "side-scroller"}, Categories: "Game;ActionGame"},
+	{Name: "adventuregame", Keywords: []string{"adventure", "roguelike", "dungeon", "point-and-click", "interactive fiction"}, Categories: "Game;AdventureGame"},
+	{Name: "roleplaying", Keywords: []string{"rpg", "role-playing", "roleplaying", "role playing"}, Categories: "Game;RolePlaying"},
+	{Name: "logicgame", Keywords: []string{"puzzle", "logic", "sudoku", "minesweeper", "brain teaser"}, Categories: "Game;LogicGame"},
+	{Name: "blocksgame", Keywords: []string{"tetris", "falling blocks"}, Categories: "Game;BlocksGame"},
+	{Name: "boardgame", Keywords: []string{"board", "board game", "chess", "goban", "chessboard", "backgammon", "mahjong", "draughts"}, Categories: "Game;BoardGame"},
+	{Name: "cardgame", Keywords: []string{"card game", "cards", "solitaire", "poker", "patience"}, Categories: "Game;CardGame"},
+	{Name: "kidsgame", Keywords: []string{"kids", "children", "toddler"}, Categories: "Game;KidsGame"},
+	{Name: "simulation", Keywords: []string{"simulation", "simulator", "flight simulator"}, Categories: "Game;Simulation"},
+	{Name: "sportsgame", Keywords: []string{"football", "soccer", "golf", "tennis", "basketball", "hockey", "sports game"}, Categories: "Game;SportsGame"},
+	{Name: "strategygame", Keywords: []string{"strategy", "rts", "turn-based", "real-time strategy", "wargame", "4x"}, Categories: "Game;StrategyGame"},
+	{Name: "game", Keywords: []string{"game", "mmorpg", "video game"}, Groups: []string{"*-games", "games"}, Categories: "Game"},
+
+	// The main categories
+	{Name: "multimedia", Keywords: []string{"multimedia", "media", "demo", "streaming"}, Groups: []string{"*-multimedia"}, Categories: "AudioVideo"},
+	{Name: "audiovideo", Keywords: []string{"audio", "sound", "music", "synth", "synthesizer"}, Groups: []string{"pro-audio"}, Categories: "AudioVideo;Audio"},
+	{Name: "video", Keywords: []string{"video", "movie", "ffmpeg", "transcoder"}, Categories: "AudioVideo;Video"},
+	{Name: "graphics", Keywords: []string{"graphics", "draw", "drawing", "image"}, Groups: []string{"*-graphics"}, Categories: "Graphics"},
+	{Name: "network", Keywords: []string{"network", "internet", "online"}, Groups: []string{"*-network"}, Categories: "Network"},
+	{Name: "office", Keywords: []string{"office", "ebook", "e-book", "documents", "pdf", "note taking", "notetaking"}, Groups: []string{"*-office", "*-pim"}, Categories: "Office"},
+	{Name: "programming", Keywords: []string{"code", "programming", "programmers", "developer", "development", "compiler", "interpreter"}, Groups: []string{"*-sdk", "*-development"}, Categories: "Development"},
+	{Name: "settings", Keywords: []string{"settings", "configuration", "configure", "preferences"}, Categories: "Settings"},
+	{Name: "system", Keywords: []string{"system", "sensor", "bus", "usb", "kernel", "boot", "virtualization", "virtualizer", "virtual machines"}, Groups: []string{"*-system", "*-admin"}, Categories: "System"},
+	{Name: "utility", Keywords: []string{"utility", "utilities", "accessories"}, Groups: []string{"*-utilities", "*-utils", "*-accessibility"}, Categories: "Utility"},
+
+	// Additional categories that are not specific to any main category
+	{Name: "consoleonly", Keywords: []string{"command-line tool", "command line tool", "command-line utility", "command line utility", "cli", "terminal-based", "console-based", "text mode", "ncurses", "tui"}, Depends: []string{"ncurses"}, Categories: "ConsoleOnly", Weight: 0.5},
+	{Name: "amusement", Keywords: []string{"amusement", "toy", "desktop toy", "fortune"}, Categories: "Amusement", Weight: 0.5},
+	{Name: "adult", Keywords: []string{"adult", "nsfw"}, Categories: "Adult", Weight: 0.5},
+
+	// Libraries that say something about what the application is for
+	{Name: "gamelibraries", Depends: []string{"sdl", "sdl_*", "sdl2", "sdl2_*", "sdl3", "allegro", "allegro4", "raylib", "sfml", "csfml", "love"}, Categories: "Game", Weight: 0.5},
+	{Name: "audiolibraries", Depends: []string{"alsa-lib", "jack", "jack2", "pipewire-jack", "libpulse", "portaudio", "libsndfile", "fluidsynth", "lv2", "ladspa"}, Categories: "AudioVideo;Audio", Weight: 0.5},
+	{Name: "videolibraries", Depends: []string{"gstreamer", "gst-plugins-*", "ffmpeg", "ffmpeg4.4", "libvlc", "vlc", "mpv", "qt5-multimedia", "qt6-multimedia", "x264", "libdvdread"}, Categories: "AudioVideo", Weight: 0.5},
+	{Name: "texlibraries", Depends: []string{"texlive", "texlive-*", "poppler", "poppler-*"}, Categories: "Office", Weight: 0.5},
+	{Name: "scanninglibraries", Depends: []string{"sane"}, Categories: "Graphics;Scanning", Weight: 0.5},
+	{Name: "ocrlibraries", Depends: []string{"tesseract", "tesseract-data-*"}, Categories: "Graphics;Scanning;OCR", Weight: 0.5},
+	{Name: "photolibraries", Depends: []string{"libgphoto2", "libraw", "exiv2", "lensfun"}, Categories: "Graphics;Photography", Weight: 0.5},
+	{Name: "graphicslibraries", Depends: []string{"gegl", "babl", "librsvg", "imagemagick", "graphicsmagick"}, Categories: "Graphics", Weight: 0.5},
+	{Name: "sciencelibraries", Depends: []string{"python-numpy", "python-scipy", "gsl", "hdf5", "netcdf", "lapack", "blas", "openblas"}, Categories: "Science", Weight: 0.5},
+	{Name: "geolibraries", Depends: []string{"gdal", "proj", "geos"}, Categories: "Science;Geoscience", Weight: 0.5},
+	{Name: "chemistrylibraries", Depends: []string{"openbabel", "rdkit"}, Categories: "Science;Chemistry", Weight: 0.5},
+	{Name: "hamradiolibraries", Depends: []string{"hamlib"}, Categories: "Network;HamRadio", Weight: 0.5},
+	{Name: "remoteaccesslibraries", Depends: []string{"freerdp", "libvncserver", "gtk-vnc"}, Categories: "Network;RemoteAccess", Weight: 0.5},
+	{Name: "p2plibraries", Depends: []string{"libtorrent", "libtorrent-rasterbar"}, Categories: "Network;P2P", Weight: 0.5},
+	{Name: "vcslibraries", Depends: []string{"git", "libgit2", "subversion", "mercurial"}, Categories: "Development;RevisionControl", Weight: 0.5},
+	{Name: "terminallibraries", Depends: []string{"vte3", "vte4", "vte-common"}, Categories: "System;TerminalEmulator", Weight: 0.5},
+
+	// Toolkits and desktop environments, which are only used together with a main category
+	{Name: "qt", Depends: []string{"qt4", "qt5-base", "qt6-base", "python-pyqt5", "python-pyqt6", "pyside2", "pyside6"}, Categories: "Qt"},
+	{Name: "kde", Depends: []string{"kio", "kio5", "kxmlgui", "kxmlgui5", "kcoreaddons", "kcoreaddons5", "kirigami", "kirigami2"}, Groups: []string{"kde-*", "kdegames", "kdeedu"}, Categories: "Qt;KDE"},
+	{Name: "gtk", Depends: []string{"gtk2", "gtk3", "gtk4", "gtkmm", "gtkmm3", "gtkmm-4.0", "python-gobject"}, Categories: "GTK"},
+	{Name: "gnome", Depends: []string{"libadwaita", "gnome-desktop", "gnome-desktop-4", "gnome-shell"}, Groups: []string{"gnome", "gnome-extra", "gnome-circle"}, Categories: "GTK;GNOME"},
+	{Name: "xfce", Depends: []string{"libxfce4ui", "libxfce4util", "xfconf"}, Groups: []string{"xfce4", "xfce4-goodies"}, Categories: "GTK;XFCE"},
+	{Name: "dde", Depends: []string{"dtkwidget", "dtkcore"}, Groups: []string{"deepin", "deepin-extra"}, Categories: "Qt;DDE"},
+	{Name: "motif", Depends: []string{"openmotif", "motif", "lesstif"}, Categories: "Motif"},
+	{Name: "java", Depends: []string{"java-runtime", "java-runtime=*", "java-environment", "jre*", "jdk*"}, Categories: "Java"},
+}
+
+// A keyword, dependency or package group that was found, and the rule it belongs to
+type categoryMatch struct {
+	source  string // like "description" or "dependency"
+	keyword string
+	rule    CategoryRule
+}
+
+// The result of guessing categories, with the details needed for explaining it
+type categoryGuess struct {
+	categories []string
+	matches    []categoryMatch
+	scores     map[string]float64
+	order      []string // the categories that got a score, in the order of the rules
+}
+
+// The weight of a rule, which is 1 if not given
+func (rule CategoryRule) weight() float64 {
+	if rule.Weight == 0 {
+		return 1
+	}
+	return rule.Weight
+}
+
+// Add the weight of a rule to the score of each of its categories
+func (guess *categoryGuess) add(source, keyword string, rule CategoryRule) {
+	guess.matches = append(guess.matches, categoryMatch{source, keyword, rule})
+	for _, category := range splitList(rule.Categories) {
+		if _, found := guess.scores[category]; !found {
+			guess.order = append(guess.order, category)
+		}
+		guess.scores[category] += rule.weight()
+	}
+}
+
+// Guess the categories for a package. Each keyword that is found in the description,
+// the package name or the URL adds the weight of its rule to the categories of the rule,
+// and so does each dependency and package group that matches one of the patterns of the
+// rule. The main category with the highest score is used, together with the additional
+// categories that can be used with it. If the scores are equal, the category from the
+// rule that comes first wins.
+func guessCategories(info PackageInfo, rules []CategoryRule) *categoryGuess {
+	guess := &categoryGuess{scores: make(map[string]float64)}
+	texts := []struct {
+		source   string
+		tokens   []string
+		consumed map[int]string // the tokens that are part of a phrase, and the rule that found it
+	}{
+		{"description", tokenize(withoutBuildRemarks(info.Pkgdesc)), make(map[int]string)},
+		{"package name", tokenize(nameWords(info.Pkgname)), make(map[int]string)},
+		{"URL", tokenize(urlWords(info.URL)), make(map[int]string)},
+	}
+	depends := dependencyNames(info.Depends)
+	for _, rule := range rules {
+		for _, text := range texts {
+			// Words that are part of a phrase that another rule found, like "emulator"
+			// in "terminal emulator", are not used again
+			available := func(i int) bool {
+				name, found := text.consumed[i]
+				return !found || name == rule.Name
+			}
+			// Keywords with the same stems, like "render" and "rendering", only count once
+			seen := make(map[string]bool)
+			for _, keyword := range rule.Keywords {
+				phrase := tokenize(keyword)
+				if seen[strings.Join(phrase, " ")] {
+					continue
+				}
+				pos := findPhrase(text.tokens, phrase, available)
+				if pos == -1 {
+					continue
+				}
+				if len(phrase) > 1 {
+					for i := pos; i < pos+len(phrase); i++ {
+						text.consumed[i] = rule.Name
+					}
+				}
+				seen[strings.Join(phrase, " ")] = true
+				guess.add(text.source, keyword, rule)
+			}
+		}
+		for _, pattern := range rule.Depends {
+			if name := matchingName(pattern, depends); name != "" {
+				guess.add("dependency", name, rule)
+			}
+		}
+		for _, pattern := range rule.Groups {
+			if name := matchingName(pattern, info.Groups); name != "" {
+				guess.add("group", name, rule)
+			}
+		}
+	}
+	// Find the main category with the highest score
+	best := ""
+	for _, category := range guess.order {
+		if mainCategories[category] && (best == "" || guess.scores[category] > guess.scores[best]) {
+			best = category
+		}
+	}
+	if best == "" {
+		// Only additional categories, or none at all
+		for _, category := range guess.order {
+			if missingRelatedCategories(category, nil) == nil {
+				guess.categories = append(guess.categories, category)
+			}
+		}
+		return guess
+	}
+	guess.categories = []string{best}
+	// Add the additional categories that can be used together with the main category,
+	// like Audio and Video for AudioVideo, sorted by score
+	var additional []string
+	for _, category := range guess.order {
+		if category == best || (mainCategories[category] && relatedCategories[category] == nil) || !relatedToMainCategory(category, best, guess.scores) {
+			continue
+		}
+		pos := len(additional)
+		for pos > 0 && guess.scores[additional[pos-1]] < guess.scores[category] {
+			pos--
+		}
+		additional = append(additional[:pos], append([]string{category}, additional[pos:]...)...)
+	}
+	guess.categories = append(guess.categories, additional...)
+	return guess
+}
+
+// Check if an additional category can be used together with the given main category,
+// either because the main category is one of its related categories, because all its
+// related categories are additional categories that also got a score, like Qt for KDE,
+// or because it has none
+func relatedToMainCategory(category, main string, scores map[string]float64) bool {
+	alternatives := relatedCategories[category]
+	if len(alternatives) == 0 {
+		return true
 	}
+	for _, alternative := range alternatives {
+		if containsString(alternative, main) {
+			return true
+		}
+		scored := true
+		for _, related := range alternative {
+			if _, found := scores[related]; !found || mainCategories[related] {
+				scored = false
+			}
+		}
+		if scored {
+			return true
+		}
+	}
+	return false
+}
+
+// Suffixes of package names that say how the package is built, rather than what it is
+var packageNameSuffixes = []string{"-git", "-svn", "-hg", "-bzr", "-bin", "-nightly", "-beta", "-appimage"}
+
+// Return the words of a package name, like "gnome chess" for "gnome-chess-git"
+func nameWords(pkgname string) string {
+	for _, suffix := range packageNameSuffixes {
+		pkgname = strings.TrimSuffix(pkgname, suffix)
+	}
+	return strings.Join(splitWords(pkgname), " ")
+}
+
+// Words in remarks like "(git version)" or "(latest development snapshot)", which say
+// how the package is built, rather than what it is
+var buildRemarkWords = map[string]bool{
+	"git": true, "svn": true, "hg": true, "bzr": true, "mercurial": true, "subversion": true,
+	"version": true, "master": true, "main": true, "trunk": true, "branch": true, "head": true,
+	"build": true, "snapshot": true, "release": true, "checkout": true, "nightly": true,
+	"development": true, "dev": true, "latest": true, "from": true, "the": true, "unstable": true,
+}
+
+var (
+	parenthesizedRemark = regexp.MustCompile(`\([^()]*\)`)
+	vcsNameSuffix       = regexp.MustCompile(`(?i)([A-Za-z0-9])-(?:git|svn|hg|bzr)\b`)
 )
 
-// Given a short description, try to guess which category it belongs to
-func GuessCategory(pkgdesc string) string {
-	var keywordList []string
-	for key := 0; key < last_kw; key++ {
-		keywordList = keywordmap[key]
-		if keywordsInDescription(pkgdesc, keywordList) {
-			return categorymap[key]
+// Remove the remarks that say how a package is built from a description, like
+// "(git version)" and the "-git" suffix in "foo-git", so that "git" does not count
+// as a keyword for packages that are only built from a git repository
+func withoutBuildRemarks(pkgdesc string) string {
+	pkgdesc = parenthesizedRemark.ReplaceAllStringFunc(pkgdesc, func(remark string) string {
+		for _, word := range splitWords(remark) {
+			if !buildRemarkWords[word] {
+				return remark
+			}
 		}
+		return " "
+	})
+	return vcsNameSuffix.ReplaceAllString(pkgdesc, "$1")
+}
+
+// Parts of URLs that are found for all kinds of packages
+var urlStopWords = map[string]bool{
+	"www": true, "git": true, "code": true, "projects": true, "project": true, "p": true,
+	"github": true, "gitlab": true, "sourceforge": true, "sf": true, "bitbucket": true,
+	"codeberg": true, "launchpad": true, "savannah": true, "nongnu": true, "pages": true,
+	"directory": true, "files": true, "download": true, "home": true, "index": true,
+}
 
+// Return the words of the host in a URL, without the top-level domain, like "games"
+// for "http://games.example.org/". Since the path is chosen by the project itself
+// for most hosts, the path is only used for SourceForge, where it may be a category,
+// like "https://sourceforge.net/directory/games/".
+func urlWords(rawurl string) string {
+	u, err := url.Parse(strings.TrimSpace(rawurl))
+	if err != nil || u.Host == "" {
+		return ""
+	}
+	labels := strings.Split(strings.ToLower(u.Hostname()), ".")
+	words := splitWords(strings.Join(labels[:len(labels)-1], " "))
+	if containsString(labels, "sourceforge") || containsString(labels, "sf") {
+		words = append(words, splitWords(strings.ToLower(u.Path))...)
 	}
-	return "Application"
+	var filtered []string
+	for _, word := range words {
+		if !urlStopWords[word] {
+			filtered = append(filtered, word)
+		}
+	}
+	return strings.Join(filtered, " ")
+}
+
+// Return the package names of dependencies, without version requirements like ">=1.2"
+func dependencyNames(depends []string) []string {
+	var names []string
+	for _, depend := range depends {
+		if pos := strings.IndexAny(depend, "<>=:"); pos != -1 {
+			depend = depend[:pos]
+		}
+		if depend = strings.TrimSpace(depend); depend != "" {
+			names = append(names, depend)
+		}
+	}
+	return names
+}
+
+// Return the first name that matches the pattern, like "gst-plugins-*", or ""
+func matchingName(pattern string, names []string) string {
+	for _, name := range names {
+		if matched, _ := path.Match(pattern, name); matched {
+			return name
+		}
+	}
+	return ""
+}
+
+// Describe which keywords matched, the scores and the resulting categories
+func (guess *categoryGuess) explain() []string {
+	var lines []string
+	if len(guess.matches) == 0 {
+		lines = append(lines, "No keywords, dependencies or groups matched")
+	}
+	for _, match := range guess.matches {
+		var found string
+		switch match.source {
+		case "dependency":
+			found = fmt.Sprintf("Dependency %q", match.keyword)
+		case "group":
+			found = fmt.Sprintf("Group %q", match.keyword)
+		default:
+			found = fmt.Sprintf("Keyword %q in the %s", match.keyword, match.source)
+		}
+		lines = append(lines, fmt.Sprintf("%s matched the rule %s: %s (weight %g)", found, match.rule.Name, match.rule.Categories, match.rule.weight()))
+	}
+	for _, category := range guess.order {
+		lines = append(lines, fmt.Sprintf("Score for %s: %g", category, guess.scores[category]))
+	}
+	if len(guess.categories) == 0 {
+		lines = append(lines, "No categories were guessed")
+	} else {
+		lines = append(lines, "Guessed categories: "+strings.Join(guess.categories, ";"))
+	}
+	return lines
+}
+
+// Given what is known about a package, try to guess which categories it belongs to
+func GuessCategory(info PackageInfo, rules []CategoryRule) string {
+	return strings.Join(guessCategories(info, rules).categories, ";")
+}
+
+// Describe how the categories for a package are guessed, one line per keyword,
+// dependency or group that matched, followed by the scores and the categories
+func ExplainCategories(info PackageInfo, rules []CategoryRule) []string {
+	return guessCategories(info, rules).explain()
 }
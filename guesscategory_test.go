@@ -0,0 +1,115 @@
+package gendesk
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+// A package description and the categories that should, or should not, be guessed for it
+type corpusEntry struct {
+	line        int
+	expected    []string
+	unexpected  []string // given as !Category
+	description string
+}
+
+// Read a file with one "Expected;Categories;!Unexpected | description" entry per line.
+// Empty lines and lines starting with # are skipped.
+func readCorpus(t *testing.T, filename string) []corpusEntry {
+	filedata, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var entries []corpusEntry
+	for i, line := range strings.Split(string(filedata), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		pos := strings.Index(line, "|")
+		if pos == -1 {
+			t.Fatalf("%s:%d: expected categories | description", filename, i+1)
+		}
+		entry := corpusEntry{line: i + 1, description: strings.TrimSpace(line[pos+1:])}
+		for _, category := range splitList(line[:pos]) {
+			if strings.HasPrefix(category, "!") {
+				entry.unexpected = append(entry.unexpected, category[1:])
+			} else {
+				entry.expected = append(entry.expected, category)
+			}
+		}
+		if len(entry.expected) == 0 || entry.description == "" {
+			t.Fatalf("%s:%d: both categories and a description are needed", filename, i+1)
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// Guess the categories for each description in categories.corpus with the built-in
+// rules, and check that the expected categories are among them
+func TestGuessCategoryCorpus(t *testing.T) {
+	for _, entry := range readCorpus(t, "categories.corpus") {
+		guessed, _ := normalizeCategories(splitList(GuessCategory(PackageInfo{Pkgdesc: entry.description}, defaultCategoryRules)))
+		for _, category := range entry.expected {
+			if !containsString(guessed, category) {
+				t.Errorf("categories.corpus:%d: %q: %s is missing from %s", entry.line, entry.description, category, strings.Join(guessed, ";"))
+			}
+		}
+		for _, category := range entry.unexpected {
+			if containsString(guessed, category) {
+				t.Errorf("categories.corpus:%d: %q: %s should not be in %s", entry.line, entry.description, category, strings.Join(guessed, ";"))
+			}
+		}
+	}
+}
+
+// Keywords match other forms of the same word, and the words of a phrase that one rule
+// found are not used again by other rules
+func TestGuessCategoryStemsAndPhrases(t *testing.T) {
+	rules := []CategoryRule{
+		{Name: "terminal", Keywords: []string{"terminal emulator"}, Categories: "System;TerminalEmulator", Weight: 2},
+		{Name: "emulator", Keywords: []string{"emulator", "emulators", "emulate"}, Categories: "Game;Emulator", Weight: 3},
+		{Name: "browser", Keywords: []string{"web browser", "browsing"}, Categories: "Network;WebBrowser"},
+	}
+	tests := []struct {
+		pkgdesc  string
+		expected string
+	}{
+		{"A fast terminal emulator", "System;TerminalEmulator"},
+		{"Terminal emulators for emulating old consoles", "Game;Emulator"},
+		{"A web browser", "Network;WebBrowser"},
+		{"Browse the web", "Network;WebBrowser"},
+		{"Browser for terminals", "Network;WebBrowser"},
+	}
+	for _, test := range tests {
+		if categories := GuessCategory(PackageInfo{Pkgdesc: test.pkgdesc}, rules); categories != test.expected {
+			t.Errorf("%q: got %s, expected %s", test.pkgdesc, categories, test.expected)
+		}
+	}
+}
+
+// Categories are also guessed from the package name, the URL, the dependencies and the
+// package groups, when the description is not enough
+func TestGuessCategorySources(t *testing.T) {
+	tests := []struct {
+		name     string
+		info     PackageInfo
+		expected string
+	}{
+		{"package name", PackageInfo{Pkgname: "gnome-chess-git", Pkgdesc: "Play the classic two-player game"}, "Game;BoardGame"},
+		{"SourceForge category", PackageInfo{Pkgname: "foo", URL: "https://sourceforge.net/directory/games/"}, "Game"},
+		{"path of other hosts", PackageInfo{Pkgname: "foo", URL: "https://github.com/someone/games"}, ""},
+		{"host name", PackageInfo{Pkgname: "foo", URL: "https://music.example.org/"}, "AudioVideo;Audio"},
+		{"versioned dependencies", PackageInfo{Pkgname: "foo", Depends: []string{"sdl2>=2.0.10", "sdl2_mixer"}}, "Game"},
+		{"dependency patterns", PackageInfo{Pkgname: "foo", Depends: []string{"gtk3", "gst-plugins-good"}}, "AudioVideo;GTK"},
+		{"package groups", PackageInfo{Pkgname: "foo", Groups: []string{"kde-games"}}, "Game;Qt;KDE"},
+		{"description before dependencies", PackageInfo{Pkgname: "foo", Pkgdesc: "Music player", Depends: []string{"sdl2"}}, "AudioVideo;Player"},
+	}
+	for _, test := range tests {
+		if categories := GuessCategory(test.info, defaultCategoryRules); categories != test.expected {
+			t.Errorf("%s: got %q, expected %q", test.name, categories, test.expected)
+		}
+	}
+}
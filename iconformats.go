@@ -0,0 +1,387 @@
+package gendesk
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"math/bits"
+	"strconv"
+	"strings"
+)
+
+// Decoders for the icon formats that are not in the standard library: ICO, BMP and XPM
+
+// The largest width or height of an icon that is decoded
+const maxIconSize = 4096
+
+// Check the width and height of an image before it is decoded
+func checkImageSize(width, height int) error {
+	if width <= 0 || height <= 0 || width > maxIconSize || height > maxIconSize {
+		return errors.New("invalid image size " + strconv.Itoa(width) + "x" + strconv.Itoa(height))
+	}
+	return nil
+}
+
+// Decode an ICO file, using the largest of the images it contains. The images are
+// either PNG images or BMP images without the file header.
+func decodeICO(data []byte) (image.Image, error) {
+	if len(data) < 6 {
+		return nil, errors.New("the ICO header is too short")
+	}
+	count := int(binary.LittleEndian.Uint16(data[4:6]))
+	if count == 0 || len(data) < 6+16*count {
+		return nil, errors.New("the ICO directory is too short")
+	}
+	best, bestArea, bestDepth := -1, 0, 0
+	for i := 0; i < count; i++ {
+		entry := data[6+16*i : 6+16*(i+1)]
+		// A width or height of 0 means 256
+		width, height := int(entry[0]), int(entry[1])
+		if width == 0 {
+			width = 256
+		}
+		if height == 0 {
+			height = 256
+		}
+		depth := int(binary.LittleEndian.Uint16(entry[6:8]))
+		if area := width * height; area > bestArea || (area == bestArea && depth > bestDepth) {
+			best, bestArea, bestDepth = i, area, depth
+		}
+	}
+	entry := data[6+16*best : 6+16*(best+1)]
+	size := int(binary.LittleEndian.Uint32(entry[8:12]))
+	offset := int(binary.LittleEndian.Uint32(entry[12:16]))
+	if offset < 0 || size <= 0 || offset+size > len(data) || offset+size < offset {
+		return nil, errors.New("an ICO image is outside of the file")
+	}
+	imageData := data[offset : offset+size]
+	if bytes.HasPrefix(imageData, pngSignature) {
+		config, err := png.DecodeConfig(bytes.NewReader(imageData))
+		if err != nil {
+			return nil, err
+		}
+		if err := checkImageSize(config.Width, config.Height); err != nil {
+			return nil, err
+		}
+		return png.Decode(bytes.NewReader(imageData))
+	}
+	return decodeDIB(imageData, -1, true)
+}
+
+// Decode a BMP file
+func decodeBMP(data []byte) (image.Image, error) {
+	if len(data) < 14 {
+		return nil, errors.New("the BMP header is too short")
+	}
+	offset := int(binary.LittleEndian.Uint32(data[10:14]))
+	return decodeDIB(data[14:], offset-14, false)
+}
+
+// Decode a device independent bitmap, which starts with the BITMAPINFOHEADER. The pixels
+// start at pixelOffset, or after the header and the palette if pixelOffset is -1. In ICO
+// files, the height is doubled and the pixels are followed by a 1-bit transparency mask.
+func decodeDIB(data []byte, pixelOffset int, ico bool) (image.Image, error) {
+	if len(data) < 40 {
+		return nil, errors.New("the bitmap header is too short")
+	}
+	headerSize := int(binary.LittleEndian.Uint32(data[0:4]))
+	if headerSize < 40 || headerSize > len(data) {
+		return nil, errors.New("unsupported bitmap header")
+	}
+	width := int(int32(binary.LittleEndian.Uint32(data[4:8])))
+	height := int(int32(binary.LittleEndian.Uint32(data[8:12])))
+	depth := int(binary.LittleEndian.Uint16(data[14:16]))
+	compression := binary.LittleEndian.Uint32(data[16:20])
+	colorsUsed := int(binary.LittleEndian.Uint32(data[32:36]))
+	if ico {
+		height /= 2
+	}
+	topDown := height < 0
+	if topDown {
+		height = -height
+	}
+	if err := checkImageSize(width, height); err != nil {
+		return nil, err
+	}
+
+	// The color masks for 16 and 32 bit images
+	masks := [4]uint32{0xff0000, 0xff00, 0xff, 0xff000000}
+	tableStart := headerSize
+	switch {
+	case compression == 0:
+	case compression == 3 && (depth == 16 || depth == 32):
+		// The masks follow the header, or are part of the larger headers
+		if headerSize != 40 && headerSize < 52 {
+			return nil, errors.New("unsupported bitmap header size " + strconv.Itoa(headerSize))
+		}
+		if len(data) < 52 {
+			return nil, errors.New("the bitmap color masks are missing")
+		}
+		if headerSize == 40 {
+			tableStart += 12
+		}
+		for i := 0; i < 3; i++ {
+			masks[i] = binary.LittleEndian.Uint32(data[40+4*i : 44+4*i])
+		}
+		masks[3] = 0
+		if headerSize >= 56 {
+			masks[3] = binary.LittleEndian.Uint32(data[52:56])
+		}
+	default:
+		return nil, errors.New("unsupported bitmap compression " + strconv.Itoa(int(compression)))
+	}
+	if depth == 16 && compression == 0 {
+		masks = [4]uint32{0x7c00, 0x3e0, 0x1f, 0}
+	}
+
+	var palette []color.NRGBA
+	switch depth {
+	case 1, 4, 8:
+		if colorsUsed == 0 || colorsUsed > 1<<uint(depth) {
+			colorsUsed = 1 << uint(depth)
+		}
+		if len(data) < tableStart+4*colorsUsed {
+			return nil, errors.New("the bitmap palette is too short")
+		}
+		for i := 0; i < colorsUsed; i++ {
+			c := data[tableStart+4*i:]
+			palette = append(palette, color.NRGBA{c[2], c[1], c[0], 255})
+		}
+		tableStart += 4 * colorsUsed
+	case 16, 24, 32:
+	default:
+		return nil, errors.New("unsupported bitmap depth " + strconv.Itoa(depth))
+	}
+	if pixelOffset == -1 {
+		pixelOffset = tableStart
+	}
+
+	stride := (width*depth + 31) / 32 * 4
+	maskStride := (width + 31) / 32 * 4
+	if pixelOffset < 0 || pixelOffset+stride*height > len(data) {
+		return nil, errors.New("the bitmap pixels are missing")
+	}
+	hasMask := ico && pixelOffset+(stride+maskStride)*height <= len(data)
+
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	hasAlpha := false
+	for y := 0; y < height; y++ {
+		row := data[pixelOffset+y*stride:]
+		dy := height - 1 - y
+		if topDown {
+			dy = y
+		}
+		for x := 0; x < width; x++ {
+			var c color.NRGBA
+			switch depth {
+			case 1, 4, 8:
+				bit := x * depth
+				index := int(row[bit/8]>>uint(8-depth-bit%8)) & (1<<uint(depth) - 1)
+				if index >= len(palette) {
+					return nil, errors.New("a bitmap color is not in the palette")
+				}
+				c = palette[index]
+			case 16:
+				v := uint32(binary.LittleEndian.Uint16(row[2*x:]))
+				c = color.NRGBA{maskedValue(v, masks[0]), maskedValue(v, masks[1]), maskedValue(v, masks[2]), 255}
+			case 24:
+				c = color.NRGBA{row[3*x+2], row[3*x+1], row[3*x], 255}
+			case 32:
+				v := binary.LittleEndian.Uint32(row[4*x:])
+				c = color.NRGBA{maskedValue(v, masks[0]), maskedValue(v, masks[1]), maskedValue(v, masks[2]), 255}
+				if masks[3] != 0 {
+					c.A = maskedValue(v, masks[3])
+					hasAlpha = hasAlpha || c.A != 0
+				}
+			}
+			img.SetNRGBA(x, dy, c)
+		}
+	}
+	// An alpha channel that is all zeros is not used, as in many BMP files
+	if masks[3] != 0 && depth == 32 && !hasAlpha {
+		for i := 3; i < len(img.Pix); i += 4 {
+			img.Pix[i] = 255
+		}
+	}
+	// Icons without an alpha channel use the mask for transparency
+	if hasMask && !hasAlpha {
+		maskStart := pixelOffset + stride*height
+		for y := 0; y < height; y++ {
+			row := data[maskStart+y*maskStride:]
+			dy := height - 1 - y
+			if topDown {
+				dy = y
+			}
+			for x := 0; x < width; x++ {
+				if row[x/8]&(0x80>>uint(x%8)) != 0 {
+					img.SetNRGBA(x, dy, color.NRGBA{})
+				}
+			}
+		}
+	}
+	return img, nil
+}
+
+// Extract a color component with the given mask, scaled to 8 bits
+func maskedValue(v, mask uint32) uint8 {
+	if mask == 0 {
+		return 0
+	}
+	shift := uint(bits.TrailingZeros32(mask))
+	full := mask >> shift
+	return uint8(uint64((v&mask)>>shift) * 255 / uint64(full))
+}
+
+// The color names that are supported in XPM images, in addition to #RRGGBB and grayN
+var xpmColorNames = map[string]color.NRGBA{
+	"black":   {0, 0, 0, 255},
+	"white":   {255, 255, 255, 255},
+	"red":     {255, 0, 0, 255},
+	"green":   {0, 255, 0, 255},
+	"blue":    {0, 0, 255, 255},
+	"yellow":  {255, 255, 0, 255},
+	"cyan":    {0, 255, 255, 255},
+	"magenta": {255, 0, 255, 255},
+	"gray":    {190, 190, 190, 255},
+	"grey":    {190, 190, 190, 255},
+}
+
+// Extract the strings from the C source of an XPM image
+func xpmStrings(data []byte) []string {
+	var result []string
+	s := string(data)
+	for {
+		start := strings.Index(s, "\"")
+		if start == -1 {
+			return result
+		}
+		end := strings.Index(s[start+1:], "\"")
+		if end == -1 {
+			return result
+		}
+		result = append(result, s[start+1:start+1+end])
+		s = s[start+1+end+1:]
+	}
+}
+
+// Parse a color in an XPM image, like #ff0000, #f00, red, gray50 or None
+func parseXPMColor(value string) (color.NRGBA, error) {
+	value = strings.ToLower(value)
+	if value == "none" {
+		return color.NRGBA{}, nil
+	}
+	if c, found := xpmColorNames[value]; found {
+		return c, nil
+	}
+	// The X11 shades of gray, from gray0 to gray100
+	for _, prefix := range []string{"gray", "grey"} {
+		if n, err := strconv.Atoi(strings.TrimPrefix(value, prefix)); strings.HasPrefix(value, prefix) && err == nil && n >= 0 && n <= 100 {
+			v := uint8((n*255 + 50) / 100)
+			return color.NRGBA{v, v, v, 255}, nil
+		}
+	}
+	if strings.HasPrefix(value, "#") {
+		hex := value[1:]
+		// Use the most significant byte of each component
+		if n := len(hex); n > 0 && n%3 == 0 && n <= 12 {
+			digits := n / 3
+			var c [3]uint8
+			for i := range c {
+				component := hex[i*digits : (i+1)*digits]
+				v, err := strconv.ParseUint(component, 16, 64)
+				if err != nil {
+					return color.NRGBA{}, errors.New("invalid color " + value)
+				}
+				c[i] = uint8(v * 255 / (1<<uint(4*digits) - 1))
+			}
+			return color.NRGBA{c[0], c[1], c[2], 255}, nil
+		}
+	}
+	return color.NRGBA{}, errors.New("unsupported color " + value)
+}
+
+// Decode an XPM image, version 3
+func decodeXPM(data []byte) (image.Image, error) {
+	lines := xpmStrings(data)
+	if len(lines) == 0 {
+		return nil, errors.New("the XPM values are missing")
+	}
+	values := strings.Fields(lines[0])
+	if len(values) < 4 {
+		return nil, errors.New("the XPM values are incomplete")
+	}
+	var numbers [4]int
+	for i := range numbers {
+		n, err := strconv.Atoi(values[i])
+		if err != nil {
+			return nil, errors.New("invalid XPM value " + values[i])
+		}
+		numbers[i] = n
+	}
+	width, height, colors, cpp := numbers[0], numbers[1], numbers[2], numbers[3]
+	if err := checkImageSize(width, height); err != nil {
+		return nil, err
+	}
+	if colors <= 0 || cpp <= 0 || cpp > 4 {
+		return nil, errors.New("invalid XPM colors")
+	}
+	if len(lines) < 1+colors+height {
+		return nil, errors.New("the XPM image has too few lines")
+	}
+
+	palette := make(map[string]color.NRGBA)
+	for _, line := range lines[1 : 1+colors] {
+		if len(line) < cpp {
+			return nil, errors.New("invalid XPM color line")
+		}
+		key := line[:cpp]
+		// Pairs of a context and a color, where the color may contain spaces.
+		// The color for the c context is preferred, then the grayscale and mono colors.
+		contexts := make(map[string]string)
+		context := ""
+		for _, field := range strings.Fields(line[cpp:]) {
+			switch field {
+			case "c", "g", "g4", "m", "s":
+				context = field
+			default:
+				if context == "" {
+					return nil, errors.New("invalid XPM color line")
+				}
+				contexts[context] = strings.TrimSpace(contexts[context] + " " + field)
+			}
+		}
+		found := false
+		for _, context := range []string{"c", "g", "g4", "m"} {
+			if value, ok := contexts[context]; ok {
+				c, err := parseXPMColor(value)
+				if err != nil {
+					return nil, err
+				}
+				palette[key] = c
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, errors.New("an XPM color has no value")
+		}
+	}
+
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y, line := range lines[1+colors : 1+colors+height] {
+		if len(line) < width*cpp {
+			return nil, errors.New("an XPM line is too short")
+		}
+		for x := 0; x < width; x++ {
+			c, found := palette[line[x*cpp:(x+1)*cpp]]
+			if !found {
+				return nil, errors.New("an XPM pixel is not in the colors")
+			}
+			img.SetNRGBA(x, y, c)
+		}
+	}
+	return img, nil
+}
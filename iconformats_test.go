@@ -0,0 +1,49 @@
+package gendesk
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"testing"
+)
+
+// A bitmap header of the given size, for a 16x16 image with 32 bit color masks
+func bitfieldsHeader(headerSize int) []byte {
+	header := make([]byte, headerSize)
+	binary.LittleEndian.PutUint32(header[0:4], uint32(headerSize))
+	binary.LittleEndian.PutUint32(header[4:8], 16)
+	binary.LittleEndian.PutUint32(header[8:12], 16)
+	binary.LittleEndian.PutUint16(header[12:14], 1)
+	binary.LittleEndian.PutUint16(header[14:16], 32)
+	binary.LittleEndian.PutUint32(header[16:20], 3)
+	return header
+}
+
+// A bitmap with color masks and a header size between 40 and 52 used to read the
+// masks past the end of the data
+func TestDecodeTruncatedBitfieldsHeader(t *testing.T) {
+	var bmp bytes.Buffer
+	bmp.WriteString("BM")
+	binary.Write(&bmp, binary.LittleEndian, uint32(58))
+	binary.Write(&bmp, binary.LittleEndian, uint32(0))
+	binary.Write(&bmp, binary.LittleEndian, uint32(58))
+	bmp.Write(bitfieldsHeader(44))
+	if bmp.Len() != 58 {
+		t.Fatalf("the test BMP is %d bytes, not 58", bmp.Len())
+	}
+	if _, err := NormalizeIcon(bmp.Bytes(), DefaultIconRules); err == nil || !strings.Contains(err.Error(), "unsupported bitmap header size 44") {
+		t.Errorf("expected the BMP to be rejected because of the header size, got %v", err)
+	}
+
+	// The same header in an ICO file
+	dib := bitfieldsHeader(44)
+	var ico bytes.Buffer
+	ico.Write([]byte{0, 0, 1, 0, 1, 0})
+	ico.Write([]byte{16, 16, 0, 0, 1, 0, 32, 0})
+	binary.Write(&ico, binary.LittleEndian, uint32(len(dib)))
+	binary.Write(&ico, binary.LittleEndian, uint32(22))
+	ico.Write(dib)
+	if _, err := NormalizeIcon(ico.Bytes(), DefaultIconRules); err == nil || !strings.Contains(err.Error(), "unsupported bitmap header size 44") {
+		t.Errorf("expected the ICO to be rejected because of the header size, got %v", err)
+	}
+}
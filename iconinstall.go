@@ -0,0 +1,184 @@
+package gendesk
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/draw"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// The directory of the hicolor icon theme, relative to DESTDIR
+const hicolorDir = "usr/share/icons/hicolor"
+
+// The sizes of the directories in the hicolor icon theme, for scaling icons
+var standardIconSizes = []int{16, 22, 24, 32, 48, 64, 96, 128, 192, 256, 512}
+
+// The first bytes of every PNG image
+var pngSignature = []byte{0x89, 0x50, 0x4E, 0x47, 0x0D, 0x0A, 0x1A, 0x0A}
+
+// Read the width and height of a PNG image from the IHDR chunk, without decoding it
+func PNGSize(data []byte) (int, int, error) {
+	// The IHDR chunk must come first, right after the signature and the chunk length
+	if !bytes.HasPrefix(data, pngSignature) || len(data) < 24 || string(data[12:16]) != "IHDR" {
+		return 0, 0, errors.New("not a PNG image")
+	}
+	width := binary.BigEndian.Uint32(data[16:20])
+	height := binary.BigEndian.Uint32(data[20:24])
+	if width == 0 || height == 0 || width > 1<<20 || height > 1<<20 {
+		return 0, 0, errors.New("invalid PNG image size " + strconv.Itoa(int(width)) + "x" + strconv.Itoa(int(height)))
+	}
+	return int(width), int(height), nil
+}
+
+// Install an icon into the hicolor icon theme within destdir, as
+// usr/share/icons/hicolor/NxN/apps/name.png or usr/share/icons/hicolor/scalable/apps/name.svg.
+// PNG icons must be square, and are downscaled to the largest of the standard sizes that
+// fits, since icons are only looked for in the directories of those sizes. If scale is
+// true, a PNG icon is also downscaled to each of the smaller standard sizes. Returns the
+// names of the files that were written.
+func InstallIcon(destdir, name string, data []byte, scale bool) ([]string, error) {
+	switch imageFormat(data) {
+	case "svg":
+		filename, err := writeThemeIcon(destdir, "scalable", name+".svg", data)
+		if err != nil {
+			return nil, err
+		}
+		return []string{filename}, nil
+	case "png":
+	default:
+		return nil, errors.New("the icon for " + name + " is not a PNG or SVG image")
+	}
+	width, height, err := PNGSize(data)
+	if err != nil {
+		return nil, err
+	}
+	if width != height {
+		return nil, errors.New("the icon for " + name + " is " + strconv.Itoa(width) + "x" + strconv.Itoa(height) + ", which is not square")
+	}
+	if width < standardIconSizes[0] {
+		return nil, errors.New("the icon for " + name + " is " + strconv.Itoa(width) + "x" + strconv.Itoa(height) + ", which is smaller than " + strconv.Itoa(standardIconSizes[0]) + "x" + strconv.Itoa(standardIconSizes[0]))
+	}
+	var filenames []string
+	// Go through the standard sizes from the largest to the smallest
+	for i := len(standardIconSizes) - 1; i >= 0; i-- {
+		size := standardIconSizes[i]
+		if size > width {
+			continue
+		}
+		iconData := data
+		if size < width {
+			if iconData, err = ScalePNG(data, size); err != nil {
+				return filenames, err
+			}
+		}
+		filename, err := writeThemeIcon(destdir, strconv.Itoa(size)+"x"+strconv.Itoa(size), name+".png", iconData)
+		if err != nil {
+			return filenames, err
+		}
+		filenames = append(filenames, filename)
+		if !scale {
+			break
+		}
+	}
+	return filenames, nil
+}
+
+// Write an icon to the apps directory of the given size directory in the hicolor theme
+func writeThemeIcon(destdir, sizeDir, basename string, data []byte) (string, error) {
+	dir := filepath.Join(destdir, hicolorDir, sizeDir, "apps")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	filename := filepath.Join(dir, basename)
+	return filename, ioutil.WriteFile(filename, data, 0644)
+}
+
+// Downscale a PNG image to a square of the given size, keeping the aspect ratio and
+// centering the image. Each new pixel is the average of the pixels it covers.
+func ScalePNG(data []byte, size int) ([]byte, error) {
+	src, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	bounds := src.Bounds()
+	// Premultiplied alpha, so that transparent pixels do not darken the edges
+	rgba := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(rgba, rgba.Bounds(), src, bounds.Min, draw.Src)
+
+	// The size of the scaled image within the square
+	w, h := size, size
+	if bounds.Dx() > bounds.Dy() {
+		h = max1(size * bounds.Dy() / bounds.Dx())
+	} else if bounds.Dy() > bounds.Dx() {
+		w = max1(size * bounds.Dx() / bounds.Dy())
+	}
+	scaled := scaleRGBA(rgba, w, h)
+
+	dst := image.NewRGBA(image.Rect(0, 0, size, size))
+	offset := image.Pt((size-w)/2, (size-h)/2)
+	draw.Draw(dst, scaled.Bounds().Add(offset), scaled, image.Point{}, draw.Src)
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, dst); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Return the number, or 1 if it is smaller than 1
+func max1(n int) int {
+	if n < 1 {
+		return 1
+	}
+	return n
+}
+
+// Downscale an image to the given width and height by averaging the source pixels
+// that each of the new pixels cover, weighted by how much of them is covered
+func scaleRGBA(src *image.RGBA, w, h int) *image.RGBA {
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	sw, sh := src.Bounds().Dx(), src.Bounds().Dy()
+	xScale, yScale := float64(sw)/float64(w), float64(sh)/float64(h)
+	for dy := 0; dy < h; dy++ {
+		y0, y1 := float64(dy)*yScale, float64(dy+1)*yScale
+		for dx := 0; dx < w; dx++ {
+			x0, x1 := float64(dx)*xScale, float64(dx+1)*xScale
+			var sum [4]float64
+			for sy := int(y0); sy < sh && float64(sy) < y1; sy++ {
+				wy := minFloat(float64(sy+1), y1) - maxFloat(float64(sy), y0)
+				for sx := int(x0); sx < sw && float64(sx) < x1; sx++ {
+					weight := wy * (minFloat(float64(sx+1), x1) - maxFloat(float64(sx), x0))
+					i := src.PixOffset(sx, sy)
+					for c := 0; c < 4; c++ {
+						sum[c] += weight * float64(src.Pix[i+c])
+					}
+				}
+			}
+			area := (x1 - x0) * (y1 - y0)
+			i := dst.PixOffset(dx, dy)
+			for c := 0; c < 4; c++ {
+				dst.Pix[i+c] = uint8(sum[c]/area + 0.5)
+			}
+		}
+	}
+	return dst
+}
+
+func minFloat(a, b float64) float64 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxFloat(a, b float64) float64 {
+	if a > b {
+		return a
+	}
+	return b
+}
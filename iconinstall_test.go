@@ -0,0 +1,59 @@
+package gendesk
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func encodePNG(t *testing.T, width, height int) []byte {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewNRGBA(image.Rect(0, 0, width, height))); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// PNG icons are only installed in the directories of the standard sizes, since the
+// other directories are not searched for icons
+func TestInstallIconStandardSizes(t *testing.T) {
+	destdir, err := ioutil.TempDir("", "gendesk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(destdir)
+
+	filenames, err := InstallIcon(destdir, "foo", encodePNG(t, 100, 100), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := filepath.Join(destdir, hicolorDir, "96x96", "apps", "foo.png")
+	if len(filenames) != 1 || filenames[0] != expected {
+		t.Errorf("expected %s, got %v", expected, filenames)
+	}
+	if data, err := ioutil.ReadFile(expected); err != nil {
+		t.Error(err)
+	} else if width, height, _ := PNGSize(data); width != 96 || height != 96 {
+		t.Errorf("expected a 96x96 icon, got %dx%d", width, height)
+	}
+
+	filenames, err = InstallIcon(destdir, "bar", encodePNG(t, 48, 48), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(filenames) != 5 || !strings.Contains(filenames[0], "48x48") || !strings.Contains(filenames[4], "16x16") {
+		t.Errorf("expected 48x48 down to 16x16, got %v", filenames)
+	}
+
+	if _, err := InstallIcon(destdir, "wide", encodePNG(t, 100, 50), false); err == nil || !strings.Contains(err.Error(), "not square") {
+		t.Errorf("expected a 100x50 icon to be rejected, got %v", err)
+	}
+	if _, err := InstallIcon(destdir, "tiny", encodePNG(t, 8, 8), false); err == nil {
+		t.Error("expected an 8x8 icon to be rejected")
+	}
+}
@@ -0,0 +1,239 @@
+package gendesk
+
+import (
+	"crypto/md5"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// The MD5 of the "No icon found" image from Open Icon Library
+const OpenIconLibraryNotFoundMD5 = "12928aa3233965175ea30f5acae593bf"
+
+// The URL template for searching Open Icon Library, where %s is replaced by the icon name
+const OpenIconLibraryURL = "http://openiconlibrary.sourceforge.net/gallery2/open_icon_library-full/icons/png/48x48/apps/%s.png"
+
+// Returned by icon providers that do not have the requested icon
+var ErrIconNotFound = errors.New("no icon found")
+
+// An icon that was found by one of the icon providers
+type Icon struct {
+	Data     []byte
+	Format   string // "png" or "svg", after the icon is normalized
+	Provider string // the name of the provider that found the icon
+}
+
+// A source of icons, like an URL template, a directory or an installed icon theme
+type IconProvider interface {
+	Name() string
+	// Find the icon with the given name, or return ErrIconNotFound. The icon is
+	// validated and converted afterwards, so only the data needs to be given.
+	FindIcon(name string) (*Icon, error)
+}
+
+// Try each of the providers in turn, and return the first icon that is found and that
+// follows the rules, converted to PNG or SVG. Each provider looks for each of the names,
+// in order, before the next provider is tried. If no icon is found, the reason for each
+// provider is returned instead, like why an icon was rejected.
+func FindIcon(providers []IconProvider, rules IconRules, names ...string) (*Icon, []error) {
+	var reasons []error
+	for _, provider := range providers {
+		var reason error
+		for _, name := range names {
+			icon, err := provider.FindIcon(name)
+			if err == nil {
+				if icon, err = NormalizeIcon(icon.Data, rules); err == nil {
+					icon.Provider = provider.Name()
+					return icon, nil
+				}
+				err = errors.New(name + ": " + err.Error())
+			}
+			// Other errors are more interesting than not finding the icon
+			if reason == nil || reason == ErrIconNotFound {
+				reason = err
+			}
+		}
+		if reason != nil {
+			reasons = append(reasons, errors.New(provider.Name()+": "+reason.Error()))
+		}
+	}
+	if len(providers) == 0 {
+		reasons = append(reasons, errors.New("no icon providers are configured"))
+	}
+	return nil, reasons
+}
+
+// Icons from an URL template, where %s is replaced by the icon name. Both http://,
+// https:// and file:// URLs are supported, the latter for local mirrors.
+type urlIconProvider struct {
+	name        string
+	template    string
+	notFoundMD5 []string // the MD5 sums of placeholder images that are returned instead of an error
+	single      bool     // if the URL is the same for every name, without %s
+	client      http.Client
+}
+
+// Create a provider that looks for icons at an URL, where %s is replaced by the icon name.
+// Images with one of the given MD5 sums are placeholders, and are treated as not found,
+// like the "No icon found" image from Open Icon Library, which has OpenIconLibraryNotFoundMD5.
+func NewURLIconProvider(name, template string, notFoundMD5 []string) (IconProvider, error) {
+	if !strings.Contains(template, "%s") {
+		return nil, errors.New("the icon URL " + template + " must contain %s")
+	}
+	if !strings.HasPrefix(template, "http://") && !strings.HasPrefix(template, "https://") && !strings.HasPrefix(template, "file://") {
+		return nil, errors.New("the icon URL " + template + " must start with http://, https:// or file://")
+	}
+	return &urlIconProvider{name: name, template: template, notFoundMD5: notFoundMD5, client: http.Client{Timeout: 30 * time.Second}}, nil
+}
+
+// Create a provider that downloads the icon from a single URL, whatever the name of the
+// icon is, like the .png URL in the sources of a PKGBUILD
+func NewSingleURLIconProvider(name, iconURL string) (IconProvider, error) {
+	if !strings.HasPrefix(iconURL, "http://") && !strings.HasPrefix(iconURL, "https://") && !strings.HasPrefix(iconURL, "file://") {
+		return nil, errors.New("the icon URL " + iconURL + " must start with http://, https:// or file://")
+	}
+	return &urlIconProvider{name: name, template: iconURL, single: true, client: http.Client{Timeout: 30 * time.Second}}, nil
+}
+
+func (p *urlIconProvider) Name() string {
+	return p.name
+}
+
+func (p *urlIconProvider) FindIcon(name string) (*Icon, error) {
+	url := p.template
+	if !p.single {
+		url = strings.Replace(url, "%s", name, -1)
+	}
+	var data []byte
+	if strings.HasPrefix(url, "file://") {
+		var err error
+		data, err = ioutil.ReadFile(strings.TrimPrefix(url, "file://"))
+		if os.IsNotExist(err) {
+			return nil, ErrIconNotFound
+		} else if err != nil {
+			return nil, err
+		}
+	} else {
+		resp, err := p.client.Get(url)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		switch {
+		case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+			return nil, ErrIconNotFound
+		case resp.StatusCode != http.StatusOK:
+			return nil, errors.New("HTTP status " + resp.Status)
+		}
+		if data, err = ioutil.ReadAll(resp.Body); err != nil {
+			return nil, err
+		}
+	}
+	// Some sites return a "No icon found" image instead of an error
+	sum := fmt.Sprintf("%x", md5.Sum(data))
+	for _, notFound := range p.notFoundMD5 {
+		if strings.EqualFold(sum, notFound) {
+			return nil, ErrIconNotFound
+		}
+	}
+	return &Icon{Data: data}, nil
+}
+
+// Icons in a local directory, as name.png, name.svg or name.xpm
+type dirIconProvider struct {
+	name string
+	dir  string
+}
+
+// Create a provider that looks for name.png, name.svg or name.xpm in a directory
+func NewDirIconProvider(name, dir string) IconProvider {
+	return &dirIconProvider{name, dir}
+}
+
+func (p *dirIconProvider) Name() string {
+	return p.name
+}
+
+func (p *dirIconProvider) FindIcon(name string) (*Icon, error) {
+	filenames := make([]string, len(iconExtensions))
+	for i, ext := range iconExtensions {
+		filenames[i] = filepath.Join(p.dir, name+ext)
+	}
+	return readIconFile(filenames...)
+}
+
+// Read the first of the given icon files that exists
+func readIconFile(filenames ...string) (*Icon, error) {
+	for _, filename := range filenames {
+		data, err := ioutil.ReadFile(filename)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		return &Icon{Data: data}, nil
+	}
+	return nil, ErrIconNotFound
+}
+
+// Icons from the installed icon themes, like hicolor
+type themeIconProvider struct {
+	name   string
+	theme  string
+	size   int
+	themes iconThemes
+}
+
+// Create a provider that looks for icons in an installed icon theme, and then in the
+// themes it inherits from, hicolor and /usr/share/pixmaps. The icon that is closest to
+// the given size is used.
+func NewThemeIconProvider(name, theme string, size int) IconProvider {
+	if theme == "" {
+		theme = "hicolor"
+	}
+	return &themeIconProvider{name: name, theme: theme, size: size}
+}
+
+func (p *themeIconProvider) Name() string {
+	return p.name
+}
+
+func (p *themeIconProvider) FindIcon(name string) (*Icon, error) {
+	filename := p.themes.findIcon(name, p.size, p.theme)
+	if filename == "" {
+		return nil, ErrIconNotFound
+	}
+	return readIconFile(filename)
+}
+
+// The directories to search for data files, like icons, according to the
+// XDG Base Directory Specification
+func dataDirs() []string {
+	var dirs []string
+	if dataHome := os.Getenv("XDG_DATA_HOME"); dataHome != "" {
+		dirs = append(dirs, dataHome)
+	} else if home := os.Getenv("HOME"); home != "" {
+		dirs = append(dirs, filepath.Join(home, ".local", "share"))
+	}
+	dataDirs := os.Getenv("XDG_DATA_DIRS")
+	if dataDirs == "" {
+		dataDirs = "/usr/local/share:/usr/share"
+	}
+	for _, dir := range strings.Split(dataDirs, ":") {
+		if dir != "" {
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs
+}
+
+// The icon providers that are used if none are configured
+func DefaultIconProviders() []IconProvider {
+	openIconLibrary, _ := NewURLIconProvider("openiconlibrary", OpenIconLibraryURL, []string{OpenIconLibraryNotFoundMD5})
+	return []IconProvider{openIconLibrary}
+}
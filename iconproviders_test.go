@@ -0,0 +1,129 @@
+package gendesk
+
+import (
+	"bytes"
+	"crypto/md5"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// A single URL, like the .png URL in the sources of a PKGBUILD, gives the same icon
+// for every name, while an URL template needs %s
+func TestSingleURLIconProvider(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gendesk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	data := encodePNG(t, 32, 32)
+	filename := filepath.Join(dir, "logo.png")
+	if err := ioutil.WriteFile(filename, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := NewURLIconProvider("template", "file://"+filename, nil); err == nil {
+		t.Error("expected an URL template without a placeholder for the name to be rejected")
+	}
+	provider, err := NewSingleURLIconProvider("source", "file://"+filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	icon, reasons := FindIcon([]IconProvider{provider}, DefaultIconRules, "foo", "bar")
+	if icon == nil {
+		t.Fatal(reasons)
+	}
+	if icon.Provider != "source" || icon.Format != "png" || !bytes.HasPrefix(icon.Data, []byte("\x89PNG")) {
+		t.Errorf("expected a PNG icon from the source provider, got %s from %s", icon.Format, icon.Provider)
+	}
+	if _, err := NewSingleURLIconProvider("source", "ftp://example.org/logo.png"); err == nil {
+		t.Error("expected an ftp:// URL to be rejected")
+	}
+}
+
+// Each provider is asked for each of the names before the next provider is tried, and
+// icons that are rejected or that are "No icon found" images are skipped
+func TestFindIconOrder(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gendesk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	notFound := encodePNG(t, 48, 47)
+	files := map[string][]byte{
+		"empty/README":     []byte("no icons here"),
+		"tiny/foo.png":     encodePNG(t, 8, 8),
+		"svg/bar.svg":      []byte(`<svg xmlns="http://www.w3.org/2000/svg" width="48" height="48"><!-- comment --></svg>`),
+		"png/foo.png":      encodePNG(t, 32, 32),
+		"png/bar.png":      encodePNG(t, 48, 48),
+		"mirror/foo.png":   notFound,
+		"mirror/nopng.png": []byte("not an image"),
+	}
+	for name, data := range files {
+		filename := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filename, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	provider := func(name string) IconProvider {
+		return NewDirIconProvider(name, filepath.Join(dir, name))
+	}
+	mirror, err := NewURLIconProvider("mirror", "file://"+filepath.Join(dir, "mirror", "%s.png"), []string{fmt.Sprintf("%X", md5.Sum(notFound))})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		providers []IconProvider
+		names     []string
+		provider  string // the provider that should find the icon
+		format    string
+		size      int
+	}{
+		{[]IconProvider{provider("empty"), provider("tiny"), provider("svg"), provider("png")}, []string{"foo", "bar"}, "svg", "svg", 0},
+		{[]IconProvider{provider("png"), provider("svg")}, []string{"foo", "bar"}, "png", "png", 32},
+		{[]IconProvider{provider("png")}, []string{"baz", "bar"}, "png", "png", 48},
+		{[]IconProvider{mirror, provider("png")}, []string{"foo"}, "png", "png", 32},
+	}
+	for i, test := range tests {
+		icon, reasons := FindIcon(test.providers, DefaultIconRules, test.names...)
+		if icon == nil {
+			t.Errorf("%d: no icon found: %v", i, reasons)
+			continue
+		}
+		if icon.Provider != test.provider || icon.Format != test.format {
+			t.Errorf("%d: got %s from %s, expected %s from %s", i, icon.Format, icon.Provider, test.format, test.provider)
+		}
+		if width, _, _ := PNGSize(icon.Data); test.format == "png" && width != test.size {
+			t.Errorf("%d: got a %d pixels wide icon, expected %d", i, width, test.size)
+		}
+		if test.format == "svg" && bytes.Contains(icon.Data, []byte("comment")) {
+			t.Errorf("%d: expected the comment to be removed from the SVG icon", i)
+		}
+	}
+
+	// The reason is given for each provider, and rejected icons are more interesting
+	// than icons that are not found
+	icon, reasons := FindIcon([]IconProvider{provider("empty"), provider("tiny"), mirror}, DefaultIconRules, "foo", "nopng")
+	if icon != nil {
+		t.Fatalf("expected no icon, got %s from %s", icon.Format, icon.Provider)
+	}
+	expected := []string{"empty: " + ErrIconNotFound.Error(), "tiny: foo: ", "mirror: nopng: "}
+	if len(reasons) != len(expected) {
+		t.Fatalf("expected %d reasons, got %v", len(expected), reasons)
+	}
+	for i, reason := range reasons {
+		if !strings.HasPrefix(reason.Error(), expected[i]) {
+			t.Errorf("expected a reason starting with %q, got %q", expected[i], reason)
+		}
+	}
+	if _, reasons := FindIcon(nil, DefaultIconRules, "foo"); len(reasons) != 1 {
+		t.Errorf("expected a reason when there are no providers, got %v", reasons)
+	}
+}
@@ -0,0 +1,273 @@
+package gendesk
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Icon lookup in installed icon themes, according to the Icon Theme Specification
+// https://specifications.freedesktop.org/icon-theme-spec/latest/
+
+// The file extensions of icons that are looked for, in order
+var iconExtensions = []string{".png", ".svg", ".xpm"}
+
+// A directory in an icon theme, like 48x48/apps, as described in index.theme
+type iconDir struct {
+	path      string
+	size      int
+	scale     int
+	typ       string // Fixed, Scalable or Threshold
+	minSize   int
+	maxSize   int
+	threshold int
+}
+
+// An installed icon theme
+type iconTheme struct {
+	name     string
+	bases    []string // the directories of the theme, one per base directory it is found in
+	inherits []string
+	dirs     []iconDir
+}
+
+// The directories that icon themes are looked for in, in order
+func iconBaseDirs() []string {
+	var dirs []string
+	if home := os.Getenv("HOME"); home != "" {
+		dirs = append(dirs, filepath.Join(home, ".icons"))
+	}
+	for _, dir := range dataDirs() {
+		dirs = append(dirs, filepath.Join(dir, "icons"))
+	}
+	return append(dirs, "/usr/share/pixmaps")
+}
+
+// Parse the groups of an index.theme file, as group -> key -> value
+func parseIndexTheme(contents string) map[string]map[string]string {
+	groups := make(map[string]map[string]string)
+	var current map[string]string
+	for _, line := range strings.Split(contents, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "" || strings.HasPrefix(line, "#"):
+			continue
+		case strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]"):
+			current = make(map[string]string)
+			groups[line[1:len(line)-1]] = current
+		case current != nil && strings.Contains(line, "="):
+			pos := strings.Index(line, "=")
+			current[strings.TrimSpace(line[:pos])] = strings.TrimSpace(line[pos+1:])
+		}
+	}
+	return groups
+}
+
+// Split a list separated by commas, like the Directories key
+func splitCommas(list string) []string {
+	var result []string
+	for _, element := range strings.Split(list, ",") {
+		if element = strings.TrimSpace(element); element != "" {
+			result = append(result, element)
+		}
+	}
+	return result
+}
+
+// Return the value of a key as a number, or the given default value
+func atoiDefault(value string, defaultValue int) int {
+	if n, err := strconv.Atoi(value); err == nil {
+		return n
+	}
+	return defaultValue
+}
+
+// Find an installed icon theme in the base directories and read its index.theme
+func loadIconTheme(name string) (*iconTheme, error) {
+	theme := &iconTheme{name: name}
+	index := ""
+	for _, base := range iconBaseDirs() {
+		dir := filepath.Join(base, name)
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			continue
+		}
+		theme.bases = append(theme.bases, dir)
+		if index == "" {
+			if data, err := ioutil.ReadFile(filepath.Join(dir, "index.theme")); err == nil {
+				index = string(data)
+			}
+		}
+	}
+	if len(theme.bases) == 0 {
+		return nil, errors.New("the icon theme " + name + " is not installed")
+	}
+	if index == "" {
+		return nil, errors.New("the icon theme " + name + " has no index.theme")
+	}
+	groups := parseIndexTheme(index)
+	main := groups["Icon Theme"]
+	theme.inherits = splitCommas(main["Inherits"])
+	seen := make(map[string]bool)
+	for _, path := range splitCommas(main["Directories"] + "," + main["ScaledDirectories"]) {
+		values, found := groups[path]
+		if !found || seen[path] {
+			continue
+		}
+		seen[path] = true
+		size := atoiDefault(values["Size"], 0)
+		theme.dirs = append(theme.dirs, iconDir{
+			path:      path,
+			size:      size,
+			scale:     atoiDefault(values["Scale"], 1),
+			typ:       values["Type"],
+			minSize:   atoiDefault(values["MinSize"], size),
+			maxSize:   atoiDefault(values["MaxSize"], size),
+			threshold: atoiDefault(values["Threshold"], 2),
+		})
+	}
+	return theme, nil
+}
+
+// Check if the icons in a directory can be used at the given size without scaling
+func (d iconDir) matchesSize(size, scale int) bool {
+	if d.scale != scale {
+		return false
+	}
+	switch d.typ {
+	case "Fixed":
+		return d.size == size
+	case "Scalable":
+		return d.minSize <= size && size <= d.maxSize
+	default:
+		// Threshold is the default type
+		return d.size-d.threshold <= size && size <= d.size+d.threshold
+	}
+}
+
+// How far the icons in a directory are from the given size
+func (d iconDir) sizeDistance(size, scale int) int {
+	switch d.typ {
+	case "Fixed":
+		distance := d.size*d.scale - size*scale
+		if distance < 0 {
+			return -distance
+		}
+		return distance
+	case "Scalable":
+		if size*scale < d.minSize*d.scale {
+			return d.minSize*d.scale - size*scale
+		}
+		if size*scale > d.maxSize*d.scale {
+			return size*scale - d.maxSize*d.scale
+		}
+		return 0
+	default:
+		if size*scale < (d.size-d.threshold)*d.scale {
+			return d.minSize*d.scale - size*scale
+		}
+		if size*scale > (d.size+d.threshold)*d.scale {
+			return size*scale - d.maxSize*d.scale
+		}
+		return 0
+	}
+}
+
+// Look for an icon in the theme itself, not in the themes it inherits from.
+// An icon that matches the size is preferred, otherwise the closest one is used.
+func (theme *iconTheme) lookupIcon(name string, size, scale int) string {
+	for _, dir := range theme.dirs {
+		if !dir.matchesSize(size, scale) {
+			continue
+		}
+		for _, base := range theme.bases {
+			for _, ext := range iconExtensions {
+				filename := filepath.Join(base, dir.path, name+ext)
+				if _, err := os.Stat(filename); err == nil {
+					return filename
+				}
+			}
+		}
+	}
+	closest, minimal := "", -1
+	for _, dir := range theme.dirs {
+		for _, base := range theme.bases {
+			for _, ext := range iconExtensions {
+				filename := filepath.Join(base, dir.path, name+ext)
+				if _, err := os.Stat(filename); err != nil {
+					continue
+				}
+				if distance := dir.sizeDistance(size, scale); minimal == -1 || distance < minimal {
+					closest, minimal = filename, distance
+				}
+			}
+		}
+	}
+	return closest
+}
+
+// Look for an icon directly in the base directories, like /usr/share/pixmaps
+func lookupFallbackIcon(name string) string {
+	for _, base := range iconBaseDirs() {
+		for _, ext := range iconExtensions {
+			filename := filepath.Join(base, name+ext)
+			if _, err := os.Stat(filename); err == nil {
+				return filename
+			}
+		}
+	}
+	return ""
+}
+
+// Loads and remembers the installed icon themes, for looking up icons
+type iconThemes struct {
+	loaded map[string]*iconTheme // nil for themes that can not be loaded
+}
+
+// Return an installed icon theme, or nil if it can not be loaded
+func (t *iconThemes) get(name string) *iconTheme {
+	if t.loaded == nil {
+		t.loaded = make(map[string]*iconTheme)
+	}
+	theme, found := t.loaded[name]
+	if !found {
+		theme, _ = loadIconTheme(name)
+		t.loaded[name] = theme
+	}
+	return theme
+}
+
+// Find an icon in the given theme, then in the themes it inherits from, then in
+// hicolor and then directly in the base directories. Returns "" if not found.
+func (t *iconThemes) findIcon(name string, size int, themeName string) string {
+	visited := make(map[string]bool)
+	if filename := t.findIconHelper(name, size, themeName, visited); filename != "" {
+		return filename
+	}
+	if filename := t.findIconHelper(name, size, "hicolor", visited); filename != "" {
+		return filename
+	}
+	return lookupFallbackIcon(name)
+}
+
+func (t *iconThemes) findIconHelper(name string, size int, themeName string, visited map[string]bool) string {
+	if visited[themeName] {
+		return ""
+	}
+	visited[themeName] = true
+	theme := t.get(themeName)
+	if theme == nil {
+		return ""
+	}
+	if filename := theme.lookupIcon(name, size, 1); filename != "" {
+		return filename
+	}
+	for _, parent := range theme.inherits {
+		if filename := t.findIconHelper(name, size, parent, visited); filename != "" {
+			return filename
+		}
+	}
+	return ""
+}
@@ -0,0 +1,112 @@
+package gendesk
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Icons are looked up in the given theme, in the themes it inherits from, in hicolor and
+// then directly in the base directories, as described in the Icon Theme Specification
+func TestFindThemeIcon(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gendesk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, name := range []string{"HOME", "XDG_DATA_HOME", "XDG_DATA_DIRS"} {
+		defer os.Setenv(name, os.Getenv(name))
+	}
+	os.Setenv("HOME", filepath.Join(dir, "home"))
+	os.Setenv("XDG_DATA_HOME", filepath.Join(dir, "local"))
+	os.Setenv("XDG_DATA_DIRS", filepath.Join(dir, "share1")+":"+filepath.Join(dir, "share2"))
+
+	files := map[string]string{
+		"share1/icons/Child/index.theme": `[Icon Theme]
+Name=Child
+Inherits=Parent
+Directories=48x48/apps,scalable/apps
+
+[48x48/apps]
+Size=48
+Type=Fixed
+
+[scalable/apps]
+Size=48
+MinSize=16
+MaxSize=256
+Type=Scalable
+`,
+		"share2/icons/Parent/index.theme": `[Icon Theme]
+Name=Parent
+Inherits=Child
+Directories=32x32/apps,64x64/apps
+
+[32x32/apps]
+Size=32
+Type=Threshold
+Threshold=2
+
+[64x64/apps]
+Size=64
+Type=Fixed
+`,
+		"share1/icons/hicolor/index.theme":             "[Icon Theme]\nName=Hicolor\nDirectories=16x16/apps\n\n[16x16/apps]\nSize=16\nType=Fixed\n",
+		"share1/icons/Child/48x48/apps/exact.png":      "",
+		"share1/icons/Child/scalable/apps/exact.svg":   "",
+		"share1/icons/Child/48x48/apps/ext.xpm":        "",
+		"share1/icons/Child/48x48/apps/ext.svg":        "",
+		"share2/icons/Child/48x48/apps/merged.png":     "",
+		"local/icons/Child/48x48/apps/user.png":        "",
+		"share2/icons/Child/48x48/apps/user.png":       "",
+		"share1/icons/Child/48x48/apps/over.png":       "",
+		"share2/icons/Parent/32x32/apps/over.png":      "",
+		"share2/icons/Parent/32x32/apps/inherited.png": "",
+		"share2/icons/Parent/64x64/apps/inherited.png": "",
+		"share1/icons/hicolor/16x16/apps/hi.png":       "",
+		"share2/icons/plain.xpm":                       "",
+	}
+	for name, contents := range files {
+		filename := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filename, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		size     int
+		theme    string
+		expected string // relative to the temporary directory, or "" for not found
+	}{
+		{"exact", 48, "Child", "share1/icons/Child/48x48/apps/exact.png"},
+		{"exact", 128, "Child", "share1/icons/Child/scalable/apps/exact.svg"},
+		{"ext", 48, "Child", "share1/icons/Child/48x48/apps/ext.svg"},
+		{"merged", 48, "Child", "share2/icons/Child/48x48/apps/merged.png"},
+		{"user", 48, "Child", "local/icons/Child/48x48/apps/user.png"},
+		{"over", 32, "Child", "share1/icons/Child/48x48/apps/over.png"},
+		{"over", 32, "Parent", "share2/icons/Parent/32x32/apps/over.png"},
+		{"inherited", 33, "Child", "share2/icons/Parent/32x32/apps/inherited.png"},
+		{"inherited", 60, "Child", "share2/icons/Parent/64x64/apps/inherited.png"},
+		{"hi", 48, "Child", "share1/icons/hicolor/16x16/apps/hi.png"},
+		{"hi", 48, "Nonexistent", "share1/icons/hicolor/16x16/apps/hi.png"},
+		{"plain", 48, "Child", "share2/icons/plain.xpm"},
+		{"gendesk-missing-icon", 48, "Child", ""},
+	}
+	var themes iconThemes
+	for _, test := range tests {
+		filename := themes.findIcon(test.name, test.size, test.theme)
+		expected := ""
+		if test.expected != "" {
+			expected = filepath.Join(dir, test.expected)
+		}
+		if filename != expected {
+			t.Errorf("%s at %d in %s: got %s, expected %s", test.name, test.size, test.theme, strings.TrimPrefix(filename, dir+"/"), test.expected)
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package gendesk
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// The rules that icons must follow to be used
+type IconRules struct {
+	MinSize        int  // the smallest width and height of bitmap icons
+	AllowNonSquare bool // allow icons that are wider than they are high, or the other way around
+}
+
+// The rules that are used if none are configured
+var DefaultIconRules = IconRules{MinSize: 16}
+
+// Find the format of an image from its contents, or return "" if it is not a supported format
+func imageFormat(data []byte) string {
+	switch {
+	case bytes.HasPrefix(data, pngSignature):
+		return "png"
+	case bytes.HasPrefix(data, []byte("\xff\xd8\xff")):
+		return "jpeg"
+	case bytes.HasPrefix(data, []byte("GIF87a")) || bytes.HasPrefix(data, []byte("GIF89a")):
+		return "gif"
+	case bytes.HasPrefix(data, []byte("BM")):
+		return "bmp"
+	case bytes.HasPrefix(data, []byte{0, 0, 1, 0}):
+		return "ico"
+	case bytes.HasPrefix(bytes.TrimSpace(data), []byte("/* XPM */")):
+		return "xpm"
+	}
+	// Look for the <svg element after any XML declaration, comments or doctype
+	start := data
+	if len(start) > 1024 {
+		start = start[:1024]
+	}
+	if bytes.Contains(start, []byte("<svg")) {
+		return "svg"
+	}
+	return ""
+}
+
+// Decode a bitmap image in one of the supported formats. The size is checked first,
+// for the formats that can tell it before the image is decoded.
+func decodeIconImage(format string, data []byte) (image.Image, error) {
+	var decodeConfig func(io.Reader) (image.Config, error)
+	var decode func(io.Reader) (image.Image, error)
+	switch format {
+	case "png":
+		decodeConfig, decode = png.DecodeConfig, png.Decode
+	case "jpeg":
+		decodeConfig, decode = jpeg.DecodeConfig, jpeg.Decode
+	case "gif":
+		decodeConfig, decode = gif.DecodeConfig, gif.Decode
+	case "bmp":
+		return decodeBMP(data)
+	case "ico":
+		return decodeICO(data)
+	case "xpm":
+		return decodeXPM(data)
+	default:
+		return nil, errors.New("unsupported image format")
+	}
+	config, err := decodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if err := checkImageSize(config.Width, config.Height); err != nil {
+		return nil, err
+	}
+	return decode(bytes.NewReader(data))
+}
+
+// Validate an icon and convert it to either PNG or SVG. Bitmap images are fully decoded,
+// checked against the rules and encoded again as PNG, which also strips any metadata.
+// SVG images must be well-formed XML, and comments and metadata elements are removed.
+func NormalizeIcon(data []byte, rules IconRules) (*Icon, error) {
+	format := imageFormat(data)
+	if format == "" {
+		return nil, errors.New("not a PNG, SVG, XPM, ICO, BMP, GIF or JPEG image")
+	}
+	if format == "svg" {
+		cleaned, width, height, err := cleanSVG(data)
+		if err != nil {
+			return nil, errors.New("invalid SVG image: " + err.Error())
+		}
+		// The size of an SVG image is optional, and only the proportions matter
+		if width > 0 && height > 0 && !rules.AllowNonSquare && math.Abs(width-height) > 0.01*math.Max(width, height) {
+			return nil, errors.New("the SVG image is " + strconv.FormatFloat(width, 'g', -1, 64) + "x" + strconv.FormatFloat(height, 'g', -1, 64) + ", which is not square")
+		}
+		return &Icon{Data: cleaned, Format: "svg"}, nil
+	}
+	img, err := decodeIconImage(format, data)
+	if err != nil {
+		return nil, errors.New("invalid " + strings.ToUpper(format) + " image: " + err.Error())
+	}
+	width, height := img.Bounds().Dx(), img.Bounds().Dy()
+	size := strconv.Itoa(width) + "x" + strconv.Itoa(height)
+	if width < rules.MinSize || height < rules.MinSize {
+		return nil, errors.New("the " + strings.ToUpper(format) + " image is " + size + ", which is smaller than the minimum size of " + strconv.Itoa(rules.MinSize))
+	}
+	if width != height && !rules.AllowNonSquare {
+		return nil, errors.New("the " + strings.ToUpper(format) + " image is " + size + ", which is not square")
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return &Icon{Data: buf.Bytes(), Format: "png"}, nil
+}
+
+// Parse a length in an SVG image, like 48 or 48px. Returns 0 for other units, like %.
+func parseSVGLength(value string) float64 {
+	value = strings.TrimSuffix(strings.TrimSpace(value), "px")
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil || f < 0 {
+		return 0
+	}
+	return f
+}
+
+// Check that an SVG image is well-formed XML with an svg root element, and remove the
+// comments and metadata elements. Returns the cleaned image and the width and height,
+// from the viewBox or else the width and height attributes, or 0 if not given.
+func cleanSVG(data []byte) ([]byte, float64, float64, error) {
+	d := xml.NewDecoder(bytes.NewReader(data))
+	var (
+		cut           [][2]int64 // the parts of the image to remove
+		width, height float64
+		depth         int
+		metadataDepth int   // the depth of the metadata element that is being removed, or 0
+		metadataStart int64 // where the metadata element starts
+		rootFound     bool
+	)
+	for {
+		start := d.InputOffset()
+		token, err := d.Token()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, 0, 0, err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			depth++
+			if depth == 1 {
+				if rootFound || t.Name.Local != "svg" {
+					return nil, 0, 0, errors.New("the root element is not svg")
+				}
+				rootFound = true
+				for _, attr := range t.Attr {
+					switch attr.Name.Local {
+					case "width":
+						if width == 0 {
+							width = parseSVGLength(attr.Value)
+						}
+					case "height":
+						if height == 0 {
+							height = parseSVGLength(attr.Value)
+						}
+					case "viewBox":
+						// The viewBox is preferred over the width and height
+						fields := strings.FieldsFunc(attr.Value, func(r rune) bool { return r == ',' || r == ' ' })
+						if len(fields) == 4 {
+							if w, h := parseSVGLength(fields[2]), parseSVGLength(fields[3]); w > 0 && h > 0 {
+								width, height = w, h
+							}
+						}
+					}
+				}
+			}
+			if metadataDepth == 0 && t.Name.Local == "metadata" {
+				metadataDepth, metadataStart = depth, start
+			}
+		case xml.EndElement:
+			if depth == metadataDepth {
+				cut = append(cut, [2]int64{metadataStart, d.InputOffset()})
+				metadataDepth = 0
+			}
+			depth--
+		case xml.Comment:
+			if metadataDepth == 0 {
+				cut = append(cut, [2]int64{start, d.InputOffset()})
+			}
+		}
+	}
+	if !rootFound {
+		return nil, 0, 0, errors.New("the svg element is missing")
+	}
+	var buf bytes.Buffer
+	var pos int64
+	for _, c := range cut {
+		buf.Write(data[pos:c[0]])
+		pos = c[1]
+	}
+	buf.Write(data[pos:])
+	return buf.Bytes(), width, height, nil
+}
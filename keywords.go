@@ -0,0 +1,94 @@
+package gendesk
+
+import (
+	"sort"
+	"strings"
+)
+
+// The largest number of keywords that are generated
+const maxKeywords = 10
+
+// Generate keywords for searching, from the keywords that matched when guessing the
+// categories, the words of the description and the package name. Keywords that only
+// repeat the name, or each other, and common words are left out.
+func generateKeywords(pkgname, name, pkgdesc string, guess *categoryGuess) []string {
+	var candidates []string
+	for _, match := range guess.matches {
+		if match.source == "description" || match.source == "package name" {
+			candidates = append(candidates, match.keyword)
+		}
+	}
+	// Phrases like "board game" are more useful than the single words they contain
+	sort.Stable(byWordCount(candidates))
+	for _, word := range splitWords(withoutBuildRemarks(pkgdesc)) {
+		if len(word) > 2 && !stopWords[word] && !isNumber(word) {
+			candidates = append(candidates, word)
+		}
+	}
+	// Package names like "gnome-chess" are searched for as they are
+	candidates = append(candidates, pkgname)
+	for _, word := range splitWords(nameWords(pkgname)) {
+		if !stopWords[word] {
+			candidates = append(candidates, word)
+		}
+	}
+
+	// The stems of the words that are already covered, by the name or by a keyword
+	covered := make(map[string]bool)
+	for _, token := range tokenize(name) {
+		covered[token] = true
+	}
+	var keywords []string
+	for _, candidate := range candidates {
+		if len(keywords) == maxKeywords {
+			break
+		}
+		tokens := tokenize(candidate)
+		isNew := false
+		for _, token := range tokens {
+			if !covered[token] {
+				isNew = true
+			}
+		}
+		if !isNew {
+			continue
+		}
+		for _, token := range tokens {
+			covered[token] = true
+		}
+		keywords = append(keywords, candidate)
+	}
+	return keywords
+}
+
+// Add keywords to a list of keywords, unless they are already there, ignoring the case
+func addKeywords(keywords, more []string) []string {
+	result := append([]string{}, keywords...)
+	for _, keyword := range more {
+		found := false
+		for _, existing := range result {
+			if strings.EqualFold(existing, keyword) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			result = append(result, keyword)
+		}
+	}
+	return result
+}
+
+// Sort keywords by the number of words, with the most words first
+type byWordCount []string
+
+func (b byWordCount) Len() int      { return len(b) }
+func (b byWordCount) Swap(i, j int) { b[i], b[j] = b[j], b[i] }
+func (b byWordCount) Less(i, j int) bool {
+	return len(splitWords(b[i])) > len(splitWords(b[j]))
+}
+
+// Check if a word only consists of digits
+func isNumber(word string) bool {
+	return strings.Trim(word, "0123456789") == ""
+}
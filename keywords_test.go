@@ -0,0 +1,40 @@
+package gendesk
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Keywords are generated from the matched keywords, the description and the package
+// name, without repeating the name, each other or common words
+func TestGenerateKeywords(t *testing.T) {
+	tests := []struct {
+		pkgname, name, pkgdesc string
+		expected               []string
+	}{
+		{"gnome-chess", "Chess", "Play the classic two-player board game of chess", []string{"board game", "play", "gnome-chess"}},
+		{"foo", "Foo", "A text editor (git version) for 2 programmers", []string{"text editor", "programming"}},
+		{"bar", "Bar", "One two three four five six seven eight nine ten eleven twelve", []string{"one", "three", "four", "five", "six", "seven", "eight", "nine", "ten", "eleven"}},
+		{"image-viewer", "Image Viewer", "Image viewer", nil},
+	}
+	for _, test := range tests {
+		info := PackageInfo{Pkgname: test.pkgname, Pkgdesc: test.pkgdesc}
+		keywords := generateKeywords(test.pkgname, test.name, test.pkgdesc, guessCategories(info, defaultCategoryRules))
+		if !reflect.DeepEqual(keywords, test.expected) {
+			t.Errorf("%s: got %q, expected %q", test.pkgname, keywords, test.expected)
+		}
+	}
+}
+
+// Given keywords replace the generated ones, and added keywords extend them
+func TestAddKeywords(t *testing.T) {
+	info := PackageInfo{Pkgname: "foo", Pkgdesc: "Image viewer", AddKeywords: []string{"IMAGE VIEWER", "photo"}}
+	if keywords := writeEntry(t, info, Options{}); !strings.Contains(keywords, "\nKeywords=image viewer;photo;\n") {
+		t.Errorf("expected the generated keywords and photo:\n%s", keywords)
+	}
+	info.SetVariable("_keywords", []string{"picture;slideshow"})
+	if keywords := writeEntry(t, info, Options{}); !strings.Contains(keywords, "\nKeywords=picture;slideshow;IMAGE VIEWER;photo;\n") {
+		t.Errorf("expected the given keywords and the added ones:\n%s", keywords)
+	}
+}
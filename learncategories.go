@@ -0,0 +1,231 @@
+package gendesk
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	// The number of .desktop files a word must appear in, together with a category
+	learnMinCount = 3
+	// The share of the .desktop files with a word that must have the category
+	learnMinPrecision = 0.6
+)
+
+// Categories that say which toolkit or desktop is used, rather than what the application is for
+var learnSkipCategories = map[string]bool{
+	"Core": true, "KDE": true, "GNOME": true, "XFCE": true, "DDE": true,
+	"GTK": true, "Qt": true, "Motif": true, "Java": true, "ConsoleOnly": true,
+}
+
+// The words and the categories of a .desktop file
+type learnEntry struct {
+	words      []string          // the stems of the words
+	surface    map[string]string // stem -> the word as it was written
+	categories []string
+}
+
+// Read the [Desktop Entry] group of a .desktop file, as unescaped values
+func readDesktopEntry(filename string) (map[string]string, error) {
+	filedata, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[string]string)
+	inEntry := false
+	for _, line := range strings.Split(string(filedata), "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "[") {
+			inEntry = line == "[Desktop Entry]"
+			continue
+		}
+		pos := strings.Index(line, "=")
+		if !inEntry || pos == -1 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key := strings.TrimSpace(line[:pos])
+		info, found := desktopKeys[key]
+		if !found {
+			// Localized and unknown keys are not needed
+			continue
+		}
+		unescaped, err := unescapeValue(strings.TrimSpace(line[pos+1:]), info.list)
+		if err != nil {
+			return nil, errors.New(filename + ": " + err.Error())
+		}
+		values[key] = strings.Join(unescaped, ";")
+	}
+	return values, nil
+}
+
+// Find the words and the registered categories of a .desktop file.
+// Returns nil if the file has no useful categories.
+func learnFromDesktopFile(filename string) (*learnEntry, error) {
+	values, err := readDesktopEntry(filename)
+	if err != nil {
+		return nil, err
+	}
+	if values["Type"] != "Application" {
+		return nil, nil
+	}
+	entry := &learnEntry{surface: make(map[string]string)}
+	for _, category := range splitList(values["Categories"]) {
+		if registeredCategory(category) && !reservedCategories[category] && !learnSkipCategories[category] && !containsString(entry.categories, category) {
+			entry.categories = append(entry.categories, category)
+		}
+	}
+	if len(entry.categories) == 0 {
+		return nil, nil
+	}
+	for _, key := range []string{"Comment", "GenericName", "Keywords"} {
+		for _, word := range splitWords(values[key]) {
+			token := stem(word)
+			if len(word) > 1 && !stopWords[word] && !containsString(entry.words, token) {
+				entry.words = append(entry.words, token)
+				entry.surface[token] = word
+			}
+		}
+	}
+	return entry, nil
+}
+
+// Create category rules from the entries. A word becomes a keyword for a category if
+// it is found in enough of the entries with the category, and mostly in those.
+func rulesFromEntries(entries []*learnEntry) []CategoryRule {
+	wordCount := make(map[string]int)
+	pairCount := make(map[string]map[string]int)    // category -> stem -> count
+	surfaceCount := make(map[string]map[string]int) // stem -> word -> count
+	for _, entry := range entries {
+		for _, word := range entry.words {
+			wordCount[word]++
+			if surfaceCount[word] == nil {
+				surfaceCount[word] = make(map[string]int)
+			}
+			surfaceCount[word][entry.surface[word]]++
+			for _, category := range entry.categories {
+				if pairCount[category] == nil {
+					pairCount[category] = make(map[string]int)
+				}
+				pairCount[category][word]++
+			}
+		}
+	}
+	var categories []string
+	for category := range pairCount {
+		categories = append(categories, category)
+	}
+	sort.Strings(categories)
+
+	learned := make(map[string][]string) // category -> keywords
+	for _, category := range categories {
+		counts := pairCount[category]
+		for word, count := range counts {
+			if count >= learnMinCount && float64(count)/float64(wordCount[word]) >= learnMinPrecision {
+				learned[category] = append(learned[category], word)
+			}
+		}
+		// The most common keywords first
+		sort.Sort(byCount{learned[category], counts})
+	}
+
+	var rules []CategoryRule
+	for _, category := range categories {
+		// Additional categories are used together with their (first) related categories
+		ruleCategories := category
+		if alternatives := relatedCategories[category]; len(alternatives) > 0 {
+			ruleCategories = strings.Join(alternatives[0], ";") + ";" + category
+		}
+		var keywords []string
+		for _, keyword := range learned[category] {
+			if !learnedForAdditional(learned, category, keyword) {
+				// Use the most common way of writing the word, since it is stemmed when matching
+				keywords = append(keywords, mostCommon(surfaceCount[keyword]))
+			}
+		}
+		if len(keywords) > 0 {
+			rules = append(rules, CategoryRule{Name: "learned-" + category, Keywords: keywords, Categories: ruleCategories})
+		}
+	}
+	return rules
+}
+
+// Check if a keyword for a main category is also learned for one of the additional
+// categories that are used together with it, since it would then be counted twice
+func learnedForAdditional(learned map[string][]string, category, keyword string) bool {
+	if !mainCategories[category] {
+		return false
+	}
+	for additional, keywords := range learned {
+		alternatives := relatedCategories[additional]
+		if len(alternatives) > 0 && containsString(alternatives[0], category) && containsString(keywords, keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+// Return the word that is found the most times, or the first one alphabetically
+func mostCommon(counts map[string]int) string {
+	best := ""
+	for word, count := range counts {
+		if best == "" || count > counts[best] || (count == counts[best] && word < best) {
+			best = word
+		}
+	}
+	return best
+}
+
+// Sort words by how often they are found, then alphabetically
+type byCount struct {
+	words  []string
+	counts map[string]int
+}
+
+func (b byCount) Len() int      { return len(b.words) }
+func (b byCount) Swap(i, j int) { b.words[i], b.words[j] = b.words[j], b.words[i] }
+func (b byCount) Less(i, j int) bool {
+	if b.counts[b.words[i]] != b.counts[b.words[j]] {
+		return b.counts[b.words[i]] > b.counts[b.words[j]]
+	}
+	return b.words[i] < b.words[j]
+}
+
+// Read the .desktop files in the given directories, and learn category rules from the
+// words that are often found together with a category. Returns the rules, the number of
+// .desktop files that were read, and a warning for each file that had to be skipped.
+func LearnCategoryRules(dirs []string) ([]CategoryRule, int, []string, error) {
+	var (
+		entries  []*learnEntry
+		warnings []string
+	)
+	files := 0
+	for _, dir := range dirs {
+		err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.IsDir() || !strings.HasSuffix(path, ".desktop") {
+				return nil
+			}
+			entry, err := learnFromDesktopFile(path)
+			if err != nil {
+				// Skip files that can not be read
+				warnings = append(warnings, "Skipping "+err.Error())
+				return nil
+			}
+			files++
+			if entry != nil {
+				entries = append(entries, entry)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, files, warnings, errors.New("could not read " + dir + ": " + err.Error())
+		}
+	}
+	return rulesFromEntries(entries), files, warnings, nil
+}
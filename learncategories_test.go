@@ -0,0 +1,57 @@
+package gendesk
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// Words that are often found together with a category in .desktop files become
+// keywords for that category
+func TestLearnCategoryRules(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gendesk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	files := map[string]string{
+		"a.desktop":             "[Desktop Entry]\nType=Application\nName=A\nComment=Play chess and puzzle games\nCategories=Game;BoardGame;GTK;\n",
+		"b.desktop":             "[Desktop Entry]\nType=Application\nName=B\nComment=Chess puzzles\nCategories=Game;BoardGame;\n",
+		"sub/c.desktop":         "[Desktop Entry]\nType=Application\nName=C\nKeywords=chess;puzzle;\nCategories=Game;BoardGame;\n\n[Desktop Action new]\nName=Play music\n",
+		"d.desktop":             "[Desktop Entry]\nType=Application\nName=D\nComment=Play music\nCategories=AudioVideo;Audio;\n",
+		"link.desktop":          "[Desktop Entry]\nType=Link\nName=Chess\nComment=Chess puzzles\nURL=https://example.org\nCategories=Game;BoardGame;\n",
+		"invalid.desktop":       "[Desktop Entry]\nType=Application\nComment=Chess\\x\nCategories=Game;BoardGame;\n",
+		"notdesktop.txt":        "[Desktop Entry]\nType=Application\nComment=Chess\nCategories=Game;BoardGame;\n",
+		"uncategorized.desktop": "[Desktop Entry]\nType=Application\nName=E\nComment=Chess\nCategories=GTK;X-Foo;\n",
+	}
+	for name, contents := range files {
+		filename := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filename, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	rules, count, warnings, err := LearnCategoryRules([]string{dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 6 {
+		t.Errorf("expected 6 .desktop files to be read, got %d", count)
+	}
+	if len(warnings) != 1 {
+		t.Errorf("expected a warning for invalid.desktop, got %q", warnings)
+	}
+	// The keywords are learned for BoardGame, and not also for Game, since Game
+	// is used together with BoardGame
+	expected := []CategoryRule{{Name: "learned-BoardGame", Keywords: []string{"chess", "puzzle"}, Categories: "Game;BoardGame"}}
+	if !reflect.DeepEqual(rules, expected) {
+		t.Errorf("got %+v, expected %+v", rules, expected)
+	}
+	if _, _, _, err := LearnCategoryRules([]string{filepath.Join(dir, "nonexistent")}); err == nil {
+		t.Error("expected an error for a directory that does not exist")
+	}
+}
@@ -0,0 +1,156 @@
+package gendesk
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// What is known about a (split) package, from a PKGBUILD, a .SRCINFO file or
+// the flags, for generating a .desktop file
+type PackageInfo struct {
+	Pkgname string // without the "-git" suffix, if any
+	Pkgdesc string
+	URL     string
+	Depends []string
+	Groups  []string
+	IconURL string // the first .png URL in the sources
+	Dir     string // the directory that relative filenames are relative to
+
+	// The _variables, like _exec or _categories
+	Exec             string
+	ExecArgs         string
+	Name             string
+	GenericName      string
+	Comment          string
+	MimeTypes        []string
+	Categories       []string
+	Custom           string
+	Actions          []string // as id:Name:Exec or id:Name:Exec:Icon
+	AddKeywords      []string
+	TranslationsFile string
+	PODir            string
+
+	// Additional keys, like Keywords or NoDisplay, as given with _keywords or _nodisplay
+	Optional map[string][]string
+	// Localized values, like _name_de or _comment_pt_BR
+	Translations Translations
+}
+
+// Set the value of a PKGBUILD variable, like pkgdesc, _exec or _name_de.
+// Variables that are not used for generating .desktop files are ignored.
+func (info *PackageInfo) SetVariable(variable string, values []string) {
+	// The value of the variable, with all elements of an array joined with a space
+	value := strings.Join(values, " ")
+	switch variable {
+	case "pkgdesc":
+		// Description for the package
+		info.Pkgdesc = value
+	case "url":
+		// The upstream URL, for guessing categories
+		info.URL = value
+	case "depends":
+		// Dependencies, for guessing categories
+		info.Depends = values
+	case "groups":
+		// Package groups, for guessing categories
+		info.Groups = values
+	case "_exec":
+		// Custom executable for the .desktop file per (split) package
+		info.Exec = value
+	case "_exec_args":
+		// Arguments for the executable, like a field code such as %U
+		info.ExecArgs = value
+	case "_name":
+		// Custom Name for the .desktop file per (split) package
+		info.Name = value
+	case "_genericname":
+		// Custom GenericName for the .desktop file per (split) package
+		if value != "" {
+			info.GenericName = value
+		}
+	case "_mimetype", "_mimetypes":
+		// Custom MimeType for the .desktop file per (split) package
+		info.MimeTypes = splitList(values...)
+	case "_comment":
+		// Custom Comment for the .desktop file per (split) package
+		info.Comment = value
+	case "_custom":
+		// Custom string to be added to the end
+		// of the .desktop file in question
+		info.Custom = value
+	case "_categories":
+		// Both _categories=('A;B') and _categories=('A' 'B') are supported
+		info.Categories = splitList(values...)
+	case "_actions":
+		// Launcher actions, as id:Name:Exec or id:Name:Exec:Icon
+		info.Actions = values
+	case "_add_keywords":
+		// Keywords to add to the given or generated keywords
+		info.AddKeywords = splitList(values...)
+	case "_translations":
+		// A file with localized values, like Name[de]=Name
+		info.TranslationsFile = value
+	case "_podir":
+		// A directory with .po files, like de.po, to read localized values from
+		info.PODir = value
+	default:
+		// Additional keys, like _keywords or _nodisplay
+		if k, found := optionalKeyForVariable(variable); found {
+			if info.Optional == nil {
+				info.Optional = make(map[string][]string)
+			}
+			info.Optional[k.Key] = values
+		}
+		// Localized values, like _name_de or _comment_pt_BR
+		if k, locale, found := localizedVariable(variable); found {
+			if info.Translations == nil {
+				info.Translations = make(Translations)
+			}
+			info.Translations.set(k, locale, localizedValue(k, values))
+		}
+	}
+}
+
+// Return a filename relative to the directory of the package, unless it is absolute
+func (info *PackageInfo) path(filename string) string {
+	if filepath.IsAbs(filename) || info.Dir == "" {
+		return filename
+	}
+	return filepath.Join(info.Dir, filename)
+}
+
+// Find the package with the given name, or return nil
+func findPackage(packages []PackageInfo, pkgname string) *PackageInfo {
+	for i := range packages {
+		if packages[i].Pkgname == pkgname {
+			return &packages[i]
+		}
+	}
+	return nil
+}
+
+// Read the packages from a PKGBUILD or a .SRCINFO file. A .SRCINFO file next to the
+// PKGBUILD is preferred, but the PKGBUILD is still read first, since custom _variables
+// are not included in .SRCINFO files.
+func ReadPackages(filename string) ([]PackageInfo, error) {
+	pkgbuildFilename, srcinfoFilename := filename, filepath.Join(filepath.Dir(filename), ".SRCINFO")
+	if filepath.Base(filename) == ".SRCINFO" {
+		pkgbuildFilename, srcinfoFilename = filepath.Join(filepath.Dir(filename), "PKGBUILD"), filename
+	}
+	var (
+		packages []PackageInfo
+		err      error
+	)
+	if _, statErr := os.Stat(pkgbuildFilename); statErr == nil || pkgbuildFilename == filename {
+		if packages, err = ParsePKGBUILD(pkgbuildFilename); err != nil {
+			return nil, err
+		}
+	}
+	if _, statErr := os.Stat(srcinfoFilename); statErr == nil || srcinfoFilename == filename {
+		if packages, err = parseSRCINFO(srcinfoFilename, packages); err != nil {
+			return nil, err
+		}
+	}
+	return packages, nil
+}
@@ -1,9 +1,8 @@
-package main
+package gendesk
 
 import (
-	"github.com/xyproto/term"
 	"io/ioutil"
-	"os"
+	"path/filepath"
 	"strings"
 )
 
@@ -14,138 +13,99 @@ func min(a int, b int) int {
 	return b
 }
 
-// Return a list of pkgnames for split packages
-// or just a list with the pkgname for regular packages
-func pkgList(splitpkgname string) []string {
-	center := between(splitpkgname, "(", ")")
-	if center == "" {
-		center = splitpkgname
-	}
-	if strings.Contains(center, " ") {
-		unquoted := strings.Replace(center, "\"", "", -1)
-		unquoted = strings.Replace(unquoted, "'", "", -1)
-		return strings.Split(unquoted, " ")
-	}
-	return []string{splitpkgname}
-}
-
-// Retrieve value from environment if the given value is empty
-func fromEnvIfEmpty(field *string, envVarName string) {
-	if *field == "" {
-		*field = os.Getenv(envVarName)
-	}
-}
-
-func dataFromEnvironment(pkgdesc, exec, name, genericname, mimetypes, comment, categories, custom *string) {
-	// Environment variables
-	fromEnvIfEmpty(pkgdesc, "pkgdesc")
-	fromEnvIfEmpty(exec, "_exec")
-	fromEnvIfEmpty(name, "_name")
-	fromEnvIfEmpty(genericname, "_genericname")
-	fromEnvIfEmpty(mimetypes, "_mimetypes")
-	fromEnvIfEmpty(mimetypes, "_mimetype")
-	fromEnvIfEmpty(comment, "_comment")
-	fromEnvIfEmpty(categories, "_categories")
-	fromEnvIfEmpty(custom, "_custom")
-}
-
-func parsePKGBUILD(o *term.TextOutput, filename string, iconurl *string, pkgname *string, pkgnames *[]string, pkgdescMap, execMap, nameMap, genericNameMap, mimeTypesMap, commentMap, categoriesMap, customMap *map[string]string) {
-	// Fill in the dictionaries using a PKGBUILD
+// Parse a PKGBUILD and return the (split) packages. The global assignments are
+// evaluated the same way as makepkg would, and package_*() functions may override them.
+func ParsePKGBUILD(filename string) ([]PackageInfo, error) {
 	filedata, err := ioutil.ReadFile(filename)
 	if err != nil {
-		o.ErrExit("Could not read " + filename)
+		return nil, err
 	}
-	filetext := string(filedata)
-	for _, line := range strings.Split(filetext, "\n") {
-		switch {
-		case strings.HasPrefix(line, "pkgname"):
-			*pkgname = betweenQuotesOrAfterEquals(line)
-			*pkgnames = pkgList(*pkgname)
-			// Select the first pkgname in the array as the "current" pkgname
-			if len(*pkgnames) > 0 {
-				*pkgname = (*pkgnames)[0]
-			}
-		case strings.HasPrefix(line, "package_"):
-			*pkgname = between(line, "_", "(")
-		case strings.HasPrefix(line, "pkgdesc"):
-			// Description for the package
-			pkgdesc := betweenQuotesOrAfterEquals(line)
-			// Use the last found pkgname as the key
-			if *pkgname != "" {
-				(*pkgdescMap)[*pkgname] = pkgdesc
-			}
-		case strings.HasPrefix(line, "_exec"):
-			// Custom executable for the .desktop file per (split) package
-			exec := betweenQuotesOrAfterEquals(line)
-			// Use the last found pkgname as the key
-			if *pkgname != "" {
-				(*execMap)[*pkgname] = exec
-			}
-		case strings.HasPrefix(line, "_name"):
-			// Custom Name for the .desktop file per (split) package
-			name := betweenQuotesOrAfterEquals(line)
-			// Use the last found pkgname as the key
-			if *pkgname != "" {
-				(*nameMap)[*pkgname] = name
-			}
-		case strings.HasPrefix(line, "_genericname"):
-			// Custom GenericName for the .desktop file per (split) package
-			genericName := betweenQuotesOrAfterEquals(line)
-			// Use the last found pkgname as the key
-			if (*pkgname != "") && (genericName != "") {
-				(*genericNameMap)[*pkgname] = genericName
-			}
-		case strings.HasPrefix(line, "_mimetype"):
-			// Custom MimeType for the .desktop file per (split) package
-			mimeType := betweenQuotesOrAfterEquals(line)
-			// Use the last found pkgname as the key
-			if *pkgname != "" {
-				(*mimeTypesMap)[*pkgname] = mimeType
-			}
-		case strings.HasPrefix(line, "_comment"):
-			// Custom Comment for the .desktop file per (split) package
-			comment := betweenQuotesOrAfterEquals(line)
-			// Use the last found pkgname as the key
-			if *pkgname != "" {
-				(*commentMap)[*pkgname] = comment
+	// Evaluate the global assignments in the PKGBUILD, in order, the same way as makepkg
+	// would. Assignments within package_*() functions are collected per package, and
+	// evaluated afterwards, since the functions are called after the whole PKGBUILD is sourced.
+	vars := make(shellVars)
+	bodies := make(map[string][]shellStatement)
+	// The assigned global variable names, in order
+	var globalNames []string
+
+	var (
+		function string // the function that was just declared, or is being evaluated
+		depth    int    // the current brace depth
+		splitpkg string // the package name for the current package_*() function
+	)
+	for _, st := range parseShell(lexShell(string(filedata))) {
+		switch st.kind {
+		case stmtFunction:
+			function = st.name
+		case stmtBlockStart:
+			depth++
+			if depth == 1 && strings.HasPrefix(function, "package_") {
+				// A function that is defined again replaces the earlier definition
+				splitpkg = strings.TrimPrefix(function, "package_")
+				bodies[splitpkg] = []shellStatement{}
 			}
-		case strings.HasPrefix(line, "_custom"):
-			// Custom string to be added to the end
-			// of the .desktop file in question
-			custom := betweenQuotesOrAfterEquals(line)
-			// Use the last found pkgname as the key
-			if *pkgname != "" {
-				(*customMap)[*pkgname] = custom
+		case stmtBlockEnd:
+			if depth > 0 {
+				depth--
 			}
-		case strings.HasPrefix(line, "_categories"):
-			categories := betweenQuotesOrAfterEquals(line)
-			// Use the last found pkgname as the key
-			if *pkgname != "" {
-				(*categoriesMap)[*pkgname] = categories
+			if depth == 0 {
+				// Back in the global scope
+				function, splitpkg = "", ""
 			}
-		case (strings.Contains(line, "http://") || strings.Contains(line, "https://")) && strings.Contains(line, ".png"):
-			// Only supports detecting png icon filenames when represented as just the filename or an URL starting with http/https.
-			if *iconurl == "" {
-				*iconurl = "h" + between(line, "h", "g") + "g"
-				if strings.Contains(*iconurl, "$pkgname") {
-					*iconurl = strings.Replace(*iconurl,
-						"$pkgname", *pkgname, -1)
-				}
-				if strings.Contains(*iconurl, "${pkgname}") {
-					*iconurl = strings.Replace(*iconurl,
-						"${pkgname}", *pkgname, -1)
-				}
-				if strings.Contains(*iconurl, "$") {
-					// If there are more $variables, don't bother (for now)
-					// TODO: replace all defined $variables...
-					*iconurl = ""
+		case stmtAssign:
+			switch {
+			case depth == 0 && function == "":
+				if _, found := vars[st.name]; !found {
+					globalNames = append(globalNames, st.name)
 				}
+				vars.assign(st)
+			case splitpkg != "":
+				bodies[splitpkg] = append(bodies[splitpkg], st)
 			}
+			// Assignments in other functions are not evaluated by makepkg when sourcing
 		}
+	}
+
+	// Only supports detecting png icons when given as an URL starting with http/https.
+	iconURL := pngURL(vars["source"])
+
+	// Globals are the defaults for every (split) package, package_*() functions may override them
+	var packages []PackageInfo
+	for _, name := range vars["pkgname"] {
 		// Strip the "-git" suffix, if present
-		if strings.HasSuffix(*pkgname, "-git") {
-			*pkgname = (*pkgname)[:len(*pkgname)-4]
+		info := PackageInfo{Pkgname: strings.TrimSuffix(name, "-git"), IconURL: iconURL, Dir: filepath.Dir(filename)}
+		// Evaluate the package_*() function with the global variables, in order
+		scope := vars.copy()
+		var overrideNames []string
+		for _, st := range bodies[name] {
+			if !containsString(overrideNames, st.name) {
+				overrideNames = append(overrideNames, st.name)
+			}
+			scope.assign(st)
 		}
+		for _, field := range globalNames {
+			if !containsString(overrideNames, field) {
+				info.SetVariable(field, vars[field])
+			}
+		}
+		for _, field := range overrideNames {
+			info.SetVariable(field, scope[field])
+		}
+		packages = append(packages, info)
+	}
+	return packages, nil
+}
 
+// Find the first .png URL in a list of sources.
+// The "filename::" prefix that makepkg supports is removed.
+func pngURL(sources []string) string {
+	for _, source := range sources {
+		if pos := strings.Index(source, "::"); pos != -1 {
+			source = source[pos+2:]
+		}
+		if (strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://")) && strings.HasSuffix(source, ".png") {
+			return source
+		}
 	}
+	return ""
 }
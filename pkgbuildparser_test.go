@@ -0,0 +1,138 @@
+package gendesk
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// Write a PKGBUILD to a temporary directory and parse it
+func parseTestPKGBUILD(t *testing.T, source string) []PackageInfo {
+	dir, err := ioutil.TempDir("", "gendesk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "PKGBUILD")
+	if err := ioutil.WriteFile(filename, []byte(source), 0644); err != nil {
+		t.Fatal(err)
+	}
+	packages, err := ParsePKGBUILD(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return packages
+}
+
+// The global assignments are the defaults for every split package, and the
+// assignments in the package_*() functions override them
+func TestParsePKGBUILDSplitPackages(t *testing.T) {
+	// The expected Pkgname, Pkgdesc, Name and Exec for each package
+	type expected struct{ pkgname, pkgdesc, name, exec string }
+	tests := []struct {
+		name     string
+		source   string
+		packages []expected
+	}{
+		{
+			"globals after the package functions",
+			`pkgname=(foo foo-docs)
+package_foo() {
+  _name="Foo $_edition"
+}
+package_foo-docs() {
+  pkgdesc="Documentation for foo"
+}
+pkgdesc="A foo viewer"
+_edition=Deluxe
+_exec=foobar
+`,
+			[]expected{{"foo", "A foo viewer", "Foo Deluxe", "foobar"}, {"foo-docs", "Documentation for foo", "", "foobar"}},
+		},
+		{
+			"overrides in order",
+			`pkgname=(foo bar)
+pkgdesc="Viewer"
+_name=Foo
+package_bar() {
+  _name=Bar
+  pkgdesc="$_name $pkgdesc"
+  _name+=" Plus"
+  _exec="bar --$_name"
+}
+`,
+			[]expected{{"foo", "Viewer", "Foo", ""}, {"bar", "Bar Viewer", "Bar Plus", "bar --Bar Plus"}},
+		},
+		{
+			"nested braces",
+			`pkgname=(foo bar)
+pkgdesc="Viewer"
+package_foo() {
+  if [ -n "${pkgdesc}" ]; then
+    { echo "${pkgdesc%er}"; }
+  fi
+  for f in a b; do { true; }; done
+  _exec=foo-nested
+}
+_name=Foo
+`,
+			[]expected{{"foo", "Viewer", "Foo", "foo-nested"}, {"bar", "Viewer", "Foo", ""}},
+		},
+		{
+			"assignments in other functions",
+			`pkgname=foo
+pkgdesc="Viewer"
+prepare() {
+  pkgdesc="Wrong"
+}
+function build {
+  _exec=wrong
+}
+package() {
+  _name=Wrong
+}
+_exec=foo
+`,
+			[]expected{{"foo", "Viewer", "", "foo"}},
+		},
+		{
+			"-git packages",
+			`pkgbase=foo-git
+pkgname=(foo-git foo-cli-git)
+pkgdesc="Viewer (git version)"
+package_foo-git() {
+  _name="Foo"
+}
+function package_foo-cli-git() {
+  pkgdesc="Command line viewer"
+  _exec=foo-cli
+}
+`,
+			[]expected{{"foo", "Viewer (git version)", "Foo", ""}, {"foo-cli", "Command line viewer", "", "foo-cli"}},
+		},
+		{
+			"functions that are defined twice",
+			`pkgname=(foo)
+package_foo() {
+  _name=First
+  _exec=first
+}
+package_foo() {
+  _name=Second
+}
+`,
+			[]expected{{"foo", "", "Second", ""}},
+		},
+	}
+	for _, test := range tests {
+		var got []expected
+		for _, info := range parseTestPKGBUILD(t, test.source) {
+			got = append(got, expected{info.Pkgname, info.Pkgdesc, info.Name, info.Exec})
+		}
+		if !reflect.DeepEqual(got, test.packages) {
+			t.Errorf("%s: got %q, expected %q", test.name, got, test.packages)
+		}
+	}
+}
@@ -0,0 +1,240 @@
+package gendesk
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// A 5x7 pixel font for the initials on placeholder icons, one string per row
+var placeholderFont = map[rune][7]string{
+	'A': {"01110", "10001", "10001", "11111", "10001", "10001", "10001"},
+	'B': {"11110", "10001", "10001", "11110", "10001", "10001", "11110"},
+	'C': {"01110", "10001", "10000", "10000", "10000", "10001", "01110"},
+	'D': {"11110", "10001", "10001", "10001", "10001", "10001", "11110"},
+	'E': {"11111", "10000", "10000", "11110", "10000", "10000", "11111"},
+	'F': {"11111", "10000", "10000", "11110", "10000", "10000", "10000"},
+	'G': {"01110", "10001", "10000", "10111", "10001", "10001", "01111"},
+	'H': {"10001", "10001", "10001", "11111", "10001", "10001", "10001"},
+	'I': {"01110", "00100", "00100", "00100", "00100", "00100", "01110"},
+	'J': {"00111", "00010", "00010", "00010", "00010", "10010", "01100"},
+	'K': {"10001", "10010", "10100", "11000", "10100", "10010", "10001"},
+	'L': {"10000", "10000", "10000", "10000", "10000", "10000", "11111"},
+	'M': {"10001", "11011", "10101", "10101", "10001", "10001", "10001"},
+	'N': {"10001", "10001", "11001", "10101", "10011", "10001", "10001"},
+	'O': {"01110", "10001", "10001", "10001", "10001", "10001", "01110"},
+	'P': {"11110", "10001", "10001", "11110", "10000", "10000", "10000"},
+	'Q': {"01110", "10001", "10001", "10001", "10101", "10010", "01101"},
+	'R': {"11110", "10001", "10001", "11110", "10100", "10010", "10001"},
+	'S': {"01111", "10000", "10000", "01110", "00001", "00001", "11110"},
+	'T': {"11111", "00100", "00100", "00100", "00100", "00100", "00100"},
+	'U': {"10001", "10001", "10001", "10001", "10001", "10001", "01110"},
+	'V': {"10001", "10001", "10001", "10001", "10001", "01010", "00100"},
+	'W': {"10001", "10001", "10001", "10101", "10101", "10101", "01010"},
+	'X': {"10001", "10001", "01010", "00100", "01010", "10001", "10001"},
+	'Y': {"10001", "10001", "01010", "00100", "00100", "00100", "00100"},
+	'Z': {"11111", "00001", "00010", "00100", "01000", "10000", "11111"},
+	'0': {"01110", "10001", "10011", "10101", "11001", "10001", "01110"},
+	'1': {"00100", "01100", "00100", "00100", "00100", "00100", "01110"},
+	'2': {"01110", "10001", "00001", "00010", "00100", "01000", "11111"},
+	'3': {"11111", "00010", "00100", "00010", "00001", "10001", "01110"},
+	'4': {"00010", "00110", "01010", "10010", "11111", "00010", "00010"},
+	'5': {"11111", "10000", "11110", "00001", "00001", "10001", "01110"},
+	'6': {"00110", "01000", "10000", "11110", "10001", "10001", "01110"},
+	'7': {"11111", "00001", "00010", "00100", "01000", "01000", "01000"},
+	'8': {"01110", "10001", "10001", "01110", "10001", "10001", "01110"},
+	'9': {"01110", "10001", "10001", "01111", "00001", "00010", "01100"},
+}
+
+const (
+	placeholderMargin  = 1.0 / 16 // the space around the rounded square, relative to the size
+	placeholderRadius  = 0.22     // the radius of the corners, relative to the square
+	placeholderSamples = 4        // samples per pixel in each direction, for smooth edges
+)
+
+// Find up to two initials for a name, from the first letter or digit of the first two
+// words, like "GC" for "Gnome-chess". Only A-Z and 0-9 are used.
+func placeholderInitials(name string) string {
+	words := strings.FieldsFunc(name, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	var initials []rune
+	for _, word := range words {
+		if len(initials) == 2 {
+			break
+		}
+		r := unicode.ToUpper([]rune(word)[0])
+		if _, found := placeholderFont[r]; found {
+			initials = append(initials, r)
+		}
+	}
+	return string(initials)
+}
+
+// The background color of a placeholder icon, from a hash of the package name, so that
+// each package gets its own color, but always the same one
+func placeholderColor(pkgname string) color.NRGBA {
+	h := fnv.New32a()
+	h.Write([]byte(pkgname))
+	hue := float64(h.Sum32() % 360)
+	return hslToRGB(hue, 0.55, 0.45)
+}
+
+// Convert a hue (0-360), saturation and lightness (0-1) to a color
+func hslToRGB(hue, saturation, lightness float64) color.NRGBA {
+	c := (1 - math.Abs(2*lightness-1)) * saturation
+	x := c * (1 - math.Abs(math.Mod(hue/60, 2)-1))
+	m := lightness - c/2
+	var r, g, b float64
+	switch {
+	case hue < 60:
+		r, g, b = c, x, 0
+	case hue < 120:
+		r, g, b = x, c, 0
+	case hue < 180:
+		r, g, b = 0, c, x
+	case hue < 240:
+		r, g, b = 0, x, c
+	case hue < 300:
+		r, g, b = x, 0, c
+	default:
+		r, g, b = c, 0, x
+	}
+	return color.NRGBA{uint8((r+m)*255 + 0.5), uint8((g+m)*255 + 0.5), uint8((b+m)*255 + 0.5), 255}
+}
+
+// Check if a point is within a rounded square from lo to hi
+func inRoundedSquare(x, y, lo, hi, radius float64) bool {
+	if x < lo || x > hi || y < lo || y > hi {
+		return false
+	}
+	// Only the corners need to be checked against the circles
+	cx := math.Max(lo+radius, math.Min(x, hi-radius))
+	cy := math.Max(lo+radius, math.Min(y, hi-radius))
+	return (x-cx)*(x-cx)+(y-cy)*(y-cy) <= radius*radius
+}
+
+// Check if a point is within one of the pixels of the initials, drawn with the font
+// cells of the given size, starting at left, top
+func inInitials(x, y float64, initials []rune, left, top, cell float64) bool {
+	row := int(math.Floor((y - top) / cell))
+	col := int(math.Floor((x - left) / cell))
+	if y < top || x < left || row >= 7 {
+		return false
+	}
+	// Each letter is 5 cells wide, with one cell in between
+	letter, col := col/6, col%6
+	if letter >= len(initials) || col == 5 {
+		return false
+	}
+	return placeholderFont[initials[letter]][row][col] == '1'
+}
+
+// Generate a placeholder PNG icon of the given size, as a colored rounded square with the
+// initials of the name. The color is derived from the package name.
+func PlaceholderPNG(pkgname, name string, size int) ([]byte, error) {
+	if size <= 0 {
+		return nil, errors.New("invalid icon size")
+	}
+	background := placeholderColor(pkgname)
+	initials := []rune(placeholderInitials(name))
+
+	fsize := float64(size)
+	lo, hi := fsize*placeholderMargin, fsize*(1-placeholderMargin)
+	radius := (hi - lo) * placeholderRadius
+	// The initials are half as high as the square, and at most 70% as wide
+	var cell, left, top float64
+	if n := len(initials); n > 0 {
+		columns := float64(6*n - 1)
+		cell = math.Min((hi-lo)*0.5/7, (hi-lo)*0.7/columns)
+		left = (fsize - columns*cell) / 2
+		top = (fsize - 7*cell) / 2
+	}
+
+	img := image.NewNRGBA(image.Rect(0, 0, size, size))
+	const samples = placeholderSamples * placeholderSamples
+	for py := 0; py < size; py++ {
+		for px := 0; px < size; px++ {
+			inside, text := 0, 0
+			for sy := 0; sy < placeholderSamples; sy++ {
+				for sx := 0; sx < placeholderSamples; sx++ {
+					x := float64(px) + (float64(sx)+0.5)/placeholderSamples
+					y := float64(py) + (float64(sy)+0.5)/placeholderSamples
+					if !inRoundedSquare(x, y, lo, hi, radius) {
+						continue
+					}
+					inside++
+					if len(initials) > 0 && inInitials(x, y, initials, left, top, cell) {
+						text++
+					}
+				}
+			}
+			if inside == 0 {
+				continue
+			}
+			// White initials on the background color
+			t := float64(text) / float64(inside)
+			img.SetNRGBA(px, py, color.NRGBA{
+				uint8(float64(background.R)*(1-t) + 255*t + 0.5),
+				uint8(float64(background.G)*(1-t) + 255*t + 0.5),
+				uint8(float64(background.B)*(1-t) + 255*t + 0.5),
+				uint8(255 * inside / samples),
+			})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Generate a placeholder SVG icon, that looks like the one from PlaceholderPNG
+func PlaceholderSVG(pkgname, name string) []byte {
+	background := placeholderColor(pkgname)
+	const size = 128
+	offset := size * placeholderMargin
+	side := size * (1 - 2*placeholderMargin)
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%d\" height=\"%d\" viewBox=\"0 0 %d %d\">\n", size, size, size, size)
+	fmt.Fprintf(&buf, "  <rect x=\"%g\" y=\"%g\" width=\"%g\" height=\"%g\" rx=\"%g\" fill=\"#%02x%02x%02x\"/>\n", offset, offset, side, side, side*placeholderRadius, background.R, background.G, background.B)
+	if initials := placeholderInitials(name); initials != "" {
+		fmt.Fprintf(&buf, "  <text x=\"50%%\" y=\"50%%\" dy=\"0.35em\" text-anchor=\"middle\" font-family=\"sans-serif\" font-weight=\"bold\" font-size=\"%g\" fill=\"#ffffff\">%s</text>\n", side*0.45, initials)
+	}
+	buf.WriteString("</svg>\n")
+	return buf.Bytes()
+}
+
+// Install generated placeholder icons into the hicolor icon theme within destdir, at each
+// of the standard sizes, and also as a scalable SVG icon if svg is true. Returns the names
+// of the files that were written.
+func InstallPlaceholderIcons(destdir, pkgname, name string, svg bool) ([]string, error) {
+	var filenames []string
+	for _, size := range standardIconSizes {
+		data, err := PlaceholderPNG(pkgname, name, size)
+		if err != nil {
+			return filenames, err
+		}
+		filename, err := writeThemeIcon(destdir, strconv.Itoa(size)+"x"+strconv.Itoa(size), pkgname+".png", data)
+		if err != nil {
+			return filenames, err
+		}
+		filenames = append(filenames, filename)
+	}
+	if svg {
+		filename, err := writeThemeIcon(destdir, "scalable", pkgname+".svg", PlaceholderSVG(pkgname, name))
+		if err != nil {
+			return filenames, err
+		}
+		filenames = append(filenames, filename)
+	}
+	return filenames, nil
+}
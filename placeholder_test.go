@@ -0,0 +1,135 @@
+package gendesk
+
+import (
+	"bytes"
+	"encoding/xml"
+	"image/png"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+// The initials are the first letters or digits of the first two words that have one
+func TestPlaceholderInitials(t *testing.T) {
+	tests := []struct {
+		name, initials string
+	}{
+		{"Gnome-chess", "GC"},
+		{"foo", "F"},
+		{"0 A.D.", "0A"},
+		{"a b c", "AB"},
+		{"élan vital", "V"},
+		{"日本語", ""},
+		{"", ""},
+	}
+	for _, test := range tests {
+		if initials := placeholderInitials(test.name); initials != test.initials {
+			t.Errorf("placeholderInitials(%q) = %q, expected %q", test.name, initials, test.initials)
+		}
+	}
+}
+
+// Placeholder PNG icons have the requested size, transparent corners and the color
+// of the package, and pass the icon rules
+func TestPlaceholderPNG(t *testing.T) {
+	for _, size := range []int{16, 48, 256} {
+		data, err := PlaceholderPNG("gnome-chess", "Gnome Chess", size)
+		if err != nil {
+			t.Fatal(err)
+		}
+		img, err := png.Decode(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if bounds := img.Bounds(); bounds.Dx() != size || bounds.Dy() != size {
+			t.Errorf("expected a %dx%d icon, got %v", size, size, bounds)
+		}
+		for _, corner := range [][2]int{{0, 0}, {size - 1, 0}, {0, size - 1}, {size - 1, size - 1}} {
+			if _, _, _, a := img.At(corner[0], corner[1]).RGBA(); a != 0 {
+				t.Errorf("%d: expected the corner %v to be transparent", size, corner)
+			}
+		}
+		// Near the top edge of the square, between the corners and above the initials
+		background := placeholderColor("gnome-chess")
+		if r, g, b, a := img.At(size/2, size*3/20).RGBA(); r>>8 != uint32(background.R) || g>>8 != uint32(background.G) || b>>8 != uint32(background.B) || a>>8 != 255 {
+			t.Errorf("%d: expected the background color %v, got %d,%d,%d,%d", size, background, r>>8, g>>8, b>>8, a>>8)
+		}
+		if icon, err := NormalizeIcon(data, DefaultIconRules); err != nil || icon.Format != "png" {
+			t.Errorf("%d: the placeholder icon was rejected: %v", size, err)
+		}
+		again, _ := PlaceholderPNG("gnome-chess", "Gnome Chess", size)
+		if !bytes.Equal(data, again) {
+			t.Errorf("%d: expected the same icon for the same package", size)
+		}
+	}
+	if placeholderColor("gnome-chess") == placeholderColor("gnome-mines") {
+		t.Error("expected different colors for different packages")
+	}
+	if _, err := PlaceholderPNG("foo", "Foo", 0); err == nil {
+		t.Error("expected an error for the size 0")
+	}
+}
+
+// Placeholder SVG icons are well-formed and pass the icon rules
+func TestPlaceholderSVG(t *testing.T) {
+	for _, name := range []string{"Gnome Chess", "日本語"} {
+		data := PlaceholderSVG("gnome-chess", name)
+		decoder := xml.NewDecoder(bytes.NewReader(data))
+		var elements []string
+		for {
+			token, err := decoder.Token()
+			if err == io.EOF {
+				break
+			} else if err != nil {
+				t.Fatalf("%s: %v\n%s", name, err, data)
+			}
+			if start, ok := token.(xml.StartElement); ok {
+				elements = append(elements, start.Name.Local)
+			}
+		}
+		hasText := len(elements) == 3 && elements[2] == "text"
+		if elements[0] != "svg" || elements[1] != "rect" || hasText != (name == "Gnome Chess") {
+			t.Errorf("%s: unexpected elements %q", name, elements)
+		}
+		if hasText && !bytes.Contains(data, []byte(">GC</text>")) {
+			t.Errorf("%s: expected the initials GC:\n%s", name, data)
+		}
+		if icon, err := NormalizeIcon(data, DefaultIconRules); err != nil || icon.Format != "svg" {
+			t.Errorf("%s: the placeholder icon was rejected: %v", name, err)
+		}
+	}
+}
+
+// Placeholder icons are installed at each of the standard sizes, and as an SVG icon
+func TestInstallPlaceholderIcons(t *testing.T) {
+	destdir, err := ioutil.TempDir("", "gendesk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(destdir)
+	filenames, err := InstallPlaceholderIcons(destdir, "foo", "Foo", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(filenames) != len(standardIconSizes)+1 {
+		t.Fatalf("expected %d icons, got %v", len(standardIconSizes)+1, filenames)
+	}
+	for i, size := range standardIconSizes {
+		sizeDir := strconv.Itoa(size) + "x" + strconv.Itoa(size)
+		if expected := filepath.Join(destdir, hicolorDir, sizeDir, "apps", "foo.png"); filenames[i] != expected {
+			t.Errorf("expected %s, got %s", expected, filenames[i])
+			continue
+		}
+		if data, err := ioutil.ReadFile(filenames[i]); err != nil {
+			t.Error(err)
+		} else if width, height, _ := PNGSize(data); width != size || height != size {
+			t.Errorf("%s: expected a %s icon, got %dx%d", filenames[i], sizeDir, width, height)
+		}
+	}
+	if expected := filepath.Join(destdir, hicolorDir, "scalable", "apps", "foo.svg"); filenames[len(filenames)-1] != expected {
+		t.Errorf("expected %s, got %s", expected, filenames[len(filenames)-1])
+	}
+}
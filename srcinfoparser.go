@@ -0,0 +1,97 @@
+package gendesk
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+)
+
+// Fields from a .SRCINFO file, for pkgbase or for one of the pkgname sections
+type srcinfoSection struct {
+	name   string
+	fields map[string][]string
+	keys   []string // the field names, in the order they appeared
+}
+
+// Add a value to a field. Keys like source and depends may appear several times.
+func (section *srcinfoSection) add(key, value string) {
+	if _, found := section.fields[key]; !found {
+		section.keys = append(section.keys, key)
+	}
+	section.fields[key] = append(section.fields[key], value)
+}
+
+// Split the contents of a .SRCINFO file into the pkgbase section and the pkgname sections
+func splitSRCINFO(contents string) (*srcinfoSection, []*srcinfoSection) {
+	base := &srcinfoSection{fields: make(map[string][]string)}
+	var packages []*srcinfoSection
+	current := base
+	for _, line := range strings.Split(contents, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		pos := strings.Index(line, "=")
+		if pos == -1 {
+			continue
+		}
+		key := strings.TrimSpace(line[:pos])
+		value := strings.TrimSpace(line[pos+1:])
+		switch key {
+		case "pkgbase":
+			base.name = value
+			current = base
+		case "pkgname":
+			current = &srcinfoSection{name: value, fields: make(map[string][]string)}
+			packages = append(packages, current)
+		default:
+			current.add(key, value)
+		}
+	}
+	return base, packages
+}
+
+// Parse a .SRCINFO file, as generated by makepkg --printsrcinfo, and return the (split)
+// packages. The pkgbase section gives the defaults, while each pkgname section can override them.
+func ParseSRCINFO(filename string) ([]PackageInfo, error) {
+	return parseSRCINFO(filename, nil)
+}
+
+// Parse a .SRCINFO file, starting from the given packages, like the ones from the PKGBUILD.
+// Only the packages in the .SRCINFO file are returned.
+func parseSRCINFO(filename string, known []PackageInfo) ([]PackageInfo, error) {
+	filedata, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	base, sections := splitSRCINFO(string(filedata))
+	if len(sections) == 0 {
+		// No pkgname sections, use pkgbase as the only package
+		sections = []*srcinfoSection{{name: base.name, fields: make(map[string][]string)}}
+	}
+
+	// Sources are only given in the pkgbase section
+	iconURL := pngURL(base.fields["source"])
+
+	var packages []PackageInfo
+	for _, section := range sections {
+		// Strip the "-git" suffix, if present
+		info := PackageInfo{Pkgname: strings.TrimSuffix(section.name, "-git"), Dir: filepath.Dir(filename)}
+		if existing := findPackage(known, info.Pkgname); existing != nil {
+			info = *existing
+		}
+		if iconURL != "" {
+			info.IconURL = iconURL
+		}
+		for _, field := range base.keys {
+			if _, overridden := section.fields[field]; !overridden {
+				info.SetVariable(field, base.fields[field])
+			}
+		}
+		for _, field := range section.keys {
+			info.SetVariable(field, section.fields[field])
+		}
+		packages = append(packages, info)
+	}
+	return packages, nil
+}
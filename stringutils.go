@@ -1,9 +1,25 @@
-package main
+package gendesk
 
 import (
 	"strings"
+	"unicode"
 )
 
+// Common words that say nothing about what an application is for
+var stopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "the": true, "of": true, "for": true, "to": true,
+	"in": true, "on": true, "with": true, "from": true, "by": true, "your": true, "you": true,
+	"it": true, "is": true, "or": true, "as": true, "at": true, "this": true, "that": true,
+	"application": true, "app": true, "program": true, "tool": true, "simple": true,
+	"kde": true, "gnome": true, "gtk": true, "qt": true, "new": true, "open": true,
+	"free": true, "source": true, "fast": true, "lightweight": true, "small": true,
+	"easy": true, "use": true, "based": true, "written": true, "using": true, "like": true,
+	"powerful": true, "advanced": true, "modern": true, "classic": true, "featuring": true,
+	"feature": true, "rich": true, "full": true, "featured": true, "cross": true,
+	"platform": true, "multi": true, "support": true, "two": true, "which": true,
+	"linux": true, "software": true, "version": true, "library": true,
+}
+
 // Capitalize a string or return the same if it is too short
 func capitalize(s string) string {
 	if len(s) >= 2 {
@@ -12,50 +28,89 @@ func capitalize(s string) string {
 	return s
 }
 
-// Return what's between two strings, "a" and "b", in another string
-func between(orig string, a string, b string) string {
-	if strings.Contains(orig, a) && strings.Contains(orig, b) {
-		posa := strings.Index(orig, a) + len(a)
-		posb := strings.LastIndex(orig, b)
-		return orig[posa:posb]
+// Split one or more ";" separated lists into a single list, skipping empty elements
+func splitList(lists ...string) []string {
+	var elements []string
+	for _, list := range lists {
+		for _, element := range strings.Split(list, ";") {
+			if element = strings.TrimSpace(element); element != "" {
+				elements = append(elements, element)
+			}
+		}
 	}
-	return ""
+	return elements
 }
 
-// Return the contents between "" or '' (or an empty string)
-func betweenQuotes(orig string) string {
-	var s string
-	for _, quote := range []string{"\"", "'"} {
-		s = between(orig, quote, quote)
-		if s != "" {
-			return s
+// Split a string into lowercase words. Anything that is not a letter or a digit
+// separates words, so "rendering-engine" and "e-book" are two words each.
+func splitWords(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// Split a string into words and reduce each word to its stem, for matching keywords
+func tokenize(s string) []string {
+	tokens := splitWords(s)
+	for i, word := range tokens {
+		tokens[i] = stem(word)
+	}
+	return tokens
+}
+
+// Reduce an English word to a stem with a few simple rules, by removing plurals and
+// the endings -ing, -er and -e, so that "games", "gaming" and "game" all become "gam".
+// The rules are applied until none of them match, so that stem(stem(w)) == stem(w).
+func stem(word string) string {
+	for {
+		n := len(word)
+		switch {
+		case n > 4 && strings.HasSuffix(word, "ies"):
+			word = word[:n-3] + "y"
+		case strings.HasSuffix(word, "sses"):
+			word = word[:n-2]
+		case n > 3 && strings.HasSuffix(word, "s") && !strings.HasSuffix(word, "ss") && !strings.HasSuffix(word, "us") && !strings.HasSuffix(word, "is"):
+			word = word[:n-1]
+		case n-3 >= 3 && strings.HasSuffix(word, "ing"):
+			word = undouble(word[:n-3])
+		case n-2 >= 3 && strings.HasSuffix(word, "er"):
+			word = undouble(word[:n-2])
+		case n > 3 && strings.HasSuffix(word, "e"):
+			word = word[:n-1]
+		default:
+			return word
 		}
 	}
-	return ""
 }
 
-// Return the string between the quotes or after the "=", if possible
-// or return the original string
-func betweenQuotesOrAfterEquals(orig string) string {
-	s := betweenQuotes(orig)
-	// Check for exactly one "="
-	if (s == "") && (strings.Count(orig, "=") == 1) {
-		s = strings.TrimSpace(strings.Split(orig, "=")[1])
+// Remove a doubled consonant at the end of a stem, as in "runn" from "running".
+// Double l, s and z are kept, as in "install" and "chess", and so is double m,
+// so that "programming" does not become the much more common word "program".
+func undouble(word string) string {
+	n := len(word)
+	if n >= 2 && word[n-1] == word[n-2] && strings.IndexByte("bcdfghjknpqrtvwxy", word[n-1]) != -1 {
+		return word[:n-1]
 	}
-	return s
+	return word
 }
 
-// Does a keyword exist in the string?
-// Disregards several common special characters (like -, _ and .)
-func has(s string, kw string) bool {
-	lowercase := strings.ToLower(s)
-	// Remove the most common special characters
-	massaged := strings.Trim(lowercase, "-_.,!?()[]{}\\/:;+@")
-	words := strings.Split(massaged, " ")
-	for _, word := range words {
-		if word == kw {
-			return true
+// Find a phrase (a list of tokens) in a list of tokens, where all the tokens are
+// available. Returns the position of the first token, or -1 if not found.
+func findPhrase(tokens, phrase []string, available func(int) bool) int {
+	if len(phrase) == 0 {
+		return -1
+	}
+	for i := 0; i+len(phrase) <= len(tokens); i++ {
+		found := true
+		for j, token := range phrase {
+			if tokens[i+j] != token || !available(i+j) {
+				found = false
+				break
+			}
+		}
+		if found {
+			return i
 		}
 	}
-	return false
+	return -1
 }
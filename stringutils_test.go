@@ -0,0 +1,64 @@
+package gendesk
+
+import (
+	"reflect"
+	"testing"
+)
+
+// Different forms of a word are reduced to the same stem
+func TestStem(t *testing.T) {
+	tests := []struct {
+		words []string
+		stem  string
+	}{
+		{[]string{"game", "games", "gaming", "gamer"}, "gam"},
+		{[]string{"browse", "browser", "browsers", "browsing"}, "brow"},
+		{[]string{"run", "running", "runner"}, "run"},
+		{[]string{"library", "libraries"}, "library"},
+		{[]string{"class", "classes"}, "class"},
+		{[]string{"install", "installer", "installing"}, "install"},
+		{[]string{"chess"}, "chess"},
+		{[]string{"status"}, "status"},
+		{[]string{"analysis"}, "analysis"},
+		{[]string{"gis"}, "gis"},
+		{[]string{"programming"}, "programm"},
+	}
+	for _, test := range tests {
+		for _, word := range test.words {
+			stemmed := stem(word)
+			if stemmed != test.stem {
+				t.Errorf("stem(%q) = %q, expected %q", word, stemmed, test.stem)
+			}
+			if again := stem(stemmed); again != stemmed {
+				t.Errorf("stem(%q) = %q, expected the stem to stay the same", stemmed, again)
+			}
+		}
+	}
+}
+
+// Phrases are found as consecutive tokens, and only where all the tokens are available
+func TestFindPhrase(t *testing.T) {
+	tokens := tokenize("A terminal emulator for running terminal games")
+	if expected := []string{"a", "terminal", "emulator", "for", "run", "terminal", "gam"}; !reflect.DeepEqual(tokens, expected) {
+		t.Fatalf("tokenize: got %q, expected %q", tokens, expected)
+	}
+	all := func(int) bool { return true }
+	tests := []struct {
+		phrase    string
+		available func(int) bool
+		expected  int
+	}{
+		{"terminal emulators", all, 1},
+		{"emulator", all, 2},
+		{"terminal gaming", all, 5},
+		{"terminal", func(i int) bool { return i != 1 }, 5},
+		{"emulator terminal", all, -1},
+		{"gaming terminal", all, -1},
+		{"", all, -1},
+	}
+	for _, test := range tests {
+		if pos := findPhrase(tokens, tokenize(test.phrase), test.available); pos != test.expected {
+			t.Errorf("findPhrase(%q) = %d, expected %d", test.phrase, pos, test.expected)
+		}
+	}
+}
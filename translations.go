@@ -0,0 +1,158 @@
+package gendesk
+
+import (
+	"errors"
+	"io/ioutil"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Localized values, as key (like "Name") -> locale (like "pt_BR") -> value.
+// The values for Keywords are lists separated by ";".
+type Translations map[string]map[string]string
+
+// The keys that can be localized, by the name used for flags and PKGBUILD variables
+var translatableKeys = map[string]string{
+	"name":        "Name",
+	"genericname": "GenericName",
+	"comment":     "Comment",
+	"keywords":    "Keywords",
+}
+
+// Flags like --name[de]=Name or -comment[pt_BR]=Comment
+var localizedFlagRegexp = regexp.MustCompile(`^--?([a-z]+)\[([^\]]+)\]=(.*)$`)
+
+// Set a localized value
+func (t Translations) set(key, locale, value string) {
+	if t[key] == nil {
+		t[key] = make(map[string]string)
+	}
+	t[key][locale] = value
+}
+
+// Add all the values from another set of translations, replacing existing ones
+func (t Translations) merge(other Translations) {
+	for key, locales := range other {
+		for locale, value := range locales {
+			t.set(key, locale, value)
+		}
+	}
+}
+
+// Return the locales for a key, sorted
+func (t Translations) locales(key string) []string {
+	locales := make([]string, 0, len(t[key]))
+	for locale := range t[key] {
+		locales = append(locales, locale)
+	}
+	sort.Strings(locales)
+	return locales
+}
+
+// Check if a PKGBUILD variable is a localized field, like _name_de or _comment_pt_BR.
+// Returns the key and the locale.
+func localizedVariable(variable string) (string, string, bool) {
+	for option, key := range translatableKeys {
+		prefix := "_" + option + "_"
+		if strings.HasPrefix(variable, prefix) {
+			locale := strings.TrimPrefix(variable, prefix)
+			if localeRegexp.MatchString(locale) {
+				return key, locale, true
+			}
+		}
+	}
+	return "", "", false
+}
+
+// Join the values of a localized PKGBUILD variable
+func localizedValue(key string, values []string) string {
+	if key == "Keywords" {
+		return strings.Join(splitList(values...), ";")
+	}
+	return strings.Join(values, " ")
+}
+
+// Remove flags like --name[de]=Name from the arguments, since the flag package does
+// not support them, and return the remaining arguments and the localized values
+func ExtractLocalizedFlags(args []string) ([]string, Translations, error) {
+	var remaining []string
+	localized := make(Translations)
+	for i, arg := range args {
+		if arg == "--" {
+			remaining = append(remaining, args[i:]...)
+			break
+		}
+		m := localizedFlagRegexp.FindStringSubmatch(arg)
+		if m == nil {
+			remaining = append(remaining, arg)
+			continue
+		}
+		key, found := translatableKeys[m[1]]
+		if !found {
+			return nil, nil, errors.New("the flag --" + m[1] + " can not be localized")
+		}
+		if !localeRegexp.MatchString(m[2]) {
+			return nil, nil, errors.New("invalid locale: " + m[2])
+		}
+		localized.set(key, m[2], m[3])
+	}
+	return remaining, localized, nil
+}
+
+// Read localized values from a file with lines like Name[de]=Name, in the same
+// format as in .desktop files. Other keys and lines are ignored, so an existing
+// .desktop file can be used as well.
+func readTranslationsFile(filename string) (Translations, error) {
+	filedata, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	localized := make(Translations)
+	skip := false
+	for _, line := range strings.Split(string(filedata), "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "[") {
+			// Only read the [Desktop Entry] group, or lines that are not in a group
+			skip = line != "[Desktop Entry]"
+			continue
+		}
+		pos := strings.Index(line, "=")
+		if skip || pos == -1 {
+			continue
+		}
+		m := keyNameRegexp.FindStringSubmatch(strings.TrimSpace(line[:pos]))
+		if m == nil || m[2] == "" || !isTranslatableKey(m[1]) {
+			continue
+		}
+		list := m[1] == "Keywords"
+		values, err := unescapeValue(strings.TrimSpace(line[pos+1:]), list)
+		if err != nil {
+			return nil, errors.New(filename + ": " + err.Error())
+		}
+		localized.set(m[1], m[3], strings.Join(values, ";"))
+	}
+	return localized, nil
+}
+
+// Is the key one of the keys that gendesk can write localized values for?
+func isTranslatableKey(key string) bool {
+	for _, k := range translatableKeys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// Write the localized variants of a key, sorted by locale
+func (w *desktopWriter) writeLocalized(key string, localized Translations) {
+	for _, locale := range localized.locales(key) {
+		value := localized[key][locale]
+		if key == "Keywords" {
+			w.writeList(key+"["+locale+"]", splitList(value))
+		} else {
+			w.writeString(key+"["+locale+"]", value)
+		}
+	}
+}
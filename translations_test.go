@@ -0,0 +1,91 @@
+package gendesk
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Localized values from PKGBUILD variables are written after the unlocalized key,
+// sorted by locale
+func TestWriteLocalized(t *testing.T) {
+	info := PackageInfo{Pkgname: "chess", Pkgdesc: "Chess game"}
+	info.SetVariable("_name_de", []string{"Schach"})
+	info.SetVariable("_name_pt_BR", []string{"Xadrez"})
+	info.SetVariable("_name_fr", []string{"Échecs"})
+	info.SetVariable("_comment_de", []string{"Ein", "Schachspiel"})
+	info.SetVariable("_keywords_de", []string{"Brett;Spiel", "Figuren"})
+	info.SetVariable("_name_x", []string{"not a locale"})
+	contents := writeEntry(t, info, Options{})
+	for _, expected := range []string{
+		"Name=Chess\nName[de]=Schach\nName[fr]=Échecs\nName[pt_BR]=Xadrez\n",
+		"Comment=Chess game\nComment[de]=Ein Schachspiel\n",
+		"Keywords[de]=Brett;Spiel;Figuren;\n",
+	} {
+		if !strings.Contains(contents, expected) {
+			t.Errorf("expected\n%s\nin\n%s", expected, contents)
+		}
+	}
+	if strings.Contains(contents, "not a locale") {
+		t.Errorf("_name_x should be ignored:\n%s", contents)
+	}
+}
+
+// Flags like --name[de]=Schach are removed from the arguments
+func TestExtractLocalizedFlags(t *testing.T) {
+	args := []string{"--name[de]=Schach", "--pkgname", "chess", "-comment[pt_BR]=Jogo de xadrez", "--", "--name[fr]=Échecs"}
+	remaining, localized, err := ExtractLocalizedFlags(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"--pkgname", "chess", "--", "--name[fr]=Échecs"}; !reflect.DeepEqual(remaining, expected) {
+		t.Errorf("remaining arguments %q, expected %q", remaining, expected)
+	}
+	expected := Translations{"Name": {"de": "Schach"}, "Comment": {"pt_BR": "Jogo de xadrez"}}
+	if !reflect.DeepEqual(localized, expected) {
+		t.Errorf("got %v, expected %v", localized, expected)
+	}
+	for _, arg := range []string{"--exec[de]=foo", "--name[not a locale]=foo"} {
+		if _, _, err := ExtractLocalizedFlags([]string{arg}); err == nil {
+			t.Errorf("%s: expected an error", arg)
+		}
+	}
+}
+
+// Translations files have lines like Name[de]=Schach, and may be existing .desktop files
+func TestReadTranslationsFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gendesk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "chess.desktop")
+	contents := `[Desktop Entry]
+Name=Chess
+Name[de]=Schach
+Comment[de]=Ein\sSchachspiel
+Keywords[de]=Brett\;Spiel;Figuren;
+Exec[de]=ignored
+
+[Desktop Action new]
+Name[de]=Neues Spiel
+`
+	if err := ioutil.WriteFile(filename, []byte(contents), 0644); err != nil {
+		t.Fatal(err)
+	}
+	localized, err := readTranslationsFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := Translations{
+		"Name":     {"de": "Schach"},
+		"Comment":  {"de": "Ein Schachspiel"},
+		"Keywords": {"de": "Brett;Spiel;Figuren"},
+	}
+	if !reflect.DeepEqual(localized, expected) {
+		t.Errorf("got %q, expected %q", localized, expected)
+	}
+}
@@ -0,0 +1,419 @@
+package gendesk
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+var (
+	keyNameRegexp = regexp.MustCompile(`^([A-Za-z0-9-]+)(\[([^\]]*)\])?$`)
+	localeRegexp  = regexp.MustCompile(`^[a-z]{2,3}(_[A-Z]{2})?(\.[A-Za-z0-9-]+)?(@[A-Za-z0-9]+)?$`)
+	actionRegexp  = regexp.MustCompile(`^[A-Za-z0-9-]+$`)
+)
+
+// A problem found when validating a .desktop file
+type ValidationProblem struct {
+	Line    int  // 0 if the problem is not about a specific line
+	Warning bool // errors make the file invalid, warnings do not
+	Message string
+}
+
+// The collected problems for a .desktop file
+type validationResult struct {
+	problems []ValidationProblem
+}
+
+func (r *validationResult) errorf(line int, format string, args ...interface{}) {
+	r.problems = append(r.problems, ValidationProblem{line, false, fmt.Sprintf(format, args...)})
+}
+
+func (r *validationResult) warnf(line int, format string, args ...interface{}) {
+	r.problems = append(r.problems, ValidationProblem{line, true, fmt.Sprintf(format, args...)})
+}
+
+// Are there any errors, not counting warnings?
+func (r *validationResult) hasErrors() bool {
+	for _, problem := range r.problems {
+		if !problem.Warning {
+			return true
+		}
+	}
+	return false
+}
+
+// A key/value pair in a group, with the line it was found on
+type desktopValue struct {
+	value string
+	line  int
+}
+
+// A group in a .desktop file, like [Desktop Entry]
+type desktopGroup struct {
+	name   string
+	line   int
+	values map[string]desktopValue // the keys include the [locale] suffix, if any
+}
+
+// Validate the contents of a .desktop file, according to the Desktop Entry Specification
+func validateDesktopContents(contents string) *validationResult {
+	result := &validationResult{}
+	if !utf8.ValidString(contents) {
+		result.errorf(0, "the file is not valid UTF-8")
+		return result
+	}
+
+	var (
+		groups  []*desktopGroup
+		current *desktopGroup
+	)
+	seenGroups := make(map[string]bool)
+	for i, line := range strings.Split(contents, "\n") {
+		lineno := i + 1
+		line = strings.TrimSuffix(line, "\r")
+		switch {
+		case strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#"):
+			continue
+		case strings.HasPrefix(line, "["):
+			if !strings.HasSuffix(line, "]") {
+				result.errorf(lineno, "invalid group header %q", line)
+				current = nil
+				continue
+			}
+			name := line[1 : len(line)-1]
+			if name == "" || strings.ContainsAny(name, "[]") || strings.IndexFunc(name, isControl) != -1 {
+				result.errorf(lineno, "invalid group name %q", name)
+			}
+			if seenGroups[name] {
+				result.errorf(lineno, "the group [%s] is defined more than once", name)
+			}
+			if len(groups) == 0 && name != "Desktop Entry" {
+				result.errorf(lineno, "the first group must be [Desktop Entry], not [%s]", name)
+			}
+			if name != "Desktop Entry" && !strings.HasPrefix(name, "Desktop Action ") && !strings.HasPrefix(name, "X-") {
+				result.errorf(lineno, "unknown group [%s], groups extending the format should start with X-", name)
+			}
+			seenGroups[name] = true
+			current = &desktopGroup{name, lineno, make(map[string]desktopValue)}
+			groups = append(groups, current)
+		default:
+			pos := strings.Index(line, "=")
+			if pos == -1 {
+				result.errorf(lineno, "the line is not a comment, a group header or a key=value pair")
+				continue
+			}
+			key := strings.TrimSpace(line[:pos])
+			value := strings.TrimLeft(line[pos+1:], " ")
+			if current == nil {
+				result.errorf(lineno, "the key %s is not in a group", key)
+				continue
+			}
+			if !keyNameRegexp.MatchString(key) {
+				result.errorf(lineno, "invalid key name %q", key)
+				continue
+			}
+			if _, found := current.values[key]; found {
+				result.errorf(lineno, "the key %s is defined more than once in [%s]", key, current.name)
+				continue
+			}
+			current.values[key] = desktopValue{value, lineno}
+		}
+	}
+
+	if len(groups) == 0 {
+		result.errorf(0, "the [Desktop Entry] group is missing")
+		return result
+	}
+	if groups[0].name != "Desktop Entry" {
+		// Already reported
+		sort.Stable(byLine(result.problems))
+		return result
+	}
+
+	entry := groups[0]
+	validateDesktopEntryGroup(result, entry)
+
+	// Validate the [Desktop Action id] groups, and check that they match Actions
+	var actions []string
+	if v, found := entry.values["Actions"]; found {
+		actions, _ = unescapeValue(v.value, true)
+	}
+	listed := make(map[string]bool)
+	for _, action := range actions {
+		listed[action] = true
+		if !actionRegexp.MatchString(action) {
+			result.errorf(entry.values["Actions"].line, "invalid action identifier %q", action)
+		}
+		if !seenGroups["Desktop Action "+action] {
+			result.errorf(entry.values["Actions"].line, "the action %q has no [Desktop Action %s] group", action, action)
+		}
+	}
+	for _, group := range groups[1:] {
+		if !strings.HasPrefix(group.name, "Desktop Action ") {
+			continue
+		}
+		id := strings.TrimPrefix(group.name, "Desktop Action ")
+		if !listed[id] {
+			result.warnf(group.line, "the group [%s] is not listed in the Actions key and will be ignored", group.name)
+		}
+		validateGroupKeys(result, group, actionKeys, "")
+		if _, found := group.values["Name"]; !found {
+			result.errorf(group.line, "the required key Name is missing from [%s]", group.name)
+		}
+	}
+
+	sort.Stable(byLine(result.problems))
+	return result
+}
+
+// Validate the keys and values in the [Desktop Entry] group
+func validateDesktopEntryGroup(result *validationResult, entry *desktopGroup) {
+	typ := ""
+	if v, found := entry.values["Type"]; !found {
+		result.errorf(entry.line, "the required key Type is missing")
+	} else {
+		typ = v.value
+		switch {
+		case deprecatedDesktopTypes[typ]:
+			result.warnf(v.line, "the Type %s is deprecated", typ)
+		case !desktopTypes[typ] && !strings.HasPrefix(typ, "X-"):
+			result.errorf(v.line, "unknown Type %q, should be Application, Link or Directory", typ)
+		}
+	}
+	if _, found := entry.values["Name"]; !found {
+		result.errorf(entry.line, "the required key Name is missing")
+	}
+	switch typ {
+	case "Application", "XSession":
+		dbus := entry.values["DBusActivatable"].value == "true"
+		if _, found := entry.values["Exec"]; !found && !dbus {
+			result.errorf(entry.line, "the key Exec is required for Type=%s, unless DBusActivatable=true", typ)
+		}
+	case "Link":
+		if _, found := entry.values["URL"]; !found {
+			result.errorf(entry.line, "the key URL is required for Type=Link")
+		}
+	}
+	if v, found := entry.values["Version"]; found {
+		switch v.value {
+		case "1.0", "1.1", "1.2", "1.3", "1.4", "1.5":
+		default:
+			result.warnf(v.line, "unknown Version %q", v.value)
+		}
+	}
+
+	validateGroupKeys(result, entry, desktopKeys, typ)
+
+	// Values that need more than a type check
+	if v, found := entry.values["Categories"]; found {
+		validateCategories(result, v)
+	}
+	if v, found := entry.values["Exec"]; found {
+		validateExec(result, v)
+	}
+	if v, found := entry.values["TryExec"]; found {
+		validateTryExec(result, v)
+	}
+	for _, key := range []string{"OnlyShowIn", "NotShowIn"} {
+		v, found := entry.values[key]
+		if !found {
+			continue
+		}
+		environments, _ := unescapeValue(v.value, true)
+		for _, environment := range environments {
+			if !registeredEnvironments[environment] && !strings.HasPrefix(environment, "X-") {
+				result.warnf(v.line, "%s contains the unregistered desktop environment %q", key, environment)
+			}
+		}
+	}
+	if _, found := entry.values["OnlyShowIn"]; found {
+		if _, found := entry.values["NotShowIn"]; found {
+			result.warnf(entry.line, "both OnlyShowIn and NotShowIn are given, only one of them should be used")
+		}
+	}
+	if v, found := entry.values["Icon"]; found {
+		ext := strings.ToLower(filepath.Ext(v.value))
+		if !filepath.IsAbs(v.value) && (ext == ".png" || ext == ".svg" || ext == ".xpm") {
+			result.warnf(v.line, "the icon name %q should not include a file extension, unless it is an absolute path", v.value)
+		}
+	}
+}
+
+// Validate the key names and the value types in a group
+func validateGroupKeys(result *validationResult, group *desktopGroup, known map[string]desktopKey, typ string) {
+	keys := make([]string, 0, len(group.values))
+	for key := range group.values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		v := group.values[key]
+		m := keyNameRegexp.FindStringSubmatch(key)
+		name, locale := m[1], m[3]
+		if m[2] != "" && !localeRegexp.MatchString(locale) {
+			result.errorf(v.line, "invalid locale %q in the key %s", locale, key)
+		}
+		info, found := known[name]
+		switch {
+		case strings.HasPrefix(name, "X-"):
+			continue
+		case deprecatedKeys[name]:
+			result.warnf(v.line, "the key %s is deprecated", name)
+			continue
+		case !found:
+			result.errorf(v.line, "unknown key %s in [%s], keys extending the format should start with X-", name, group.name)
+			continue
+		}
+		if m[2] != "" && info.valueType != valueLocaleString && info.valueType != valueIconString {
+			result.errorf(v.line, "the key %s can not be localized", name)
+		}
+		if info.types != nil && typ != "" && !containsString(info.types, typ) && !(typ == "XSession" && containsString(info.types, "Application")) {
+			result.warnf(v.line, "the key %s is only used for Type=%s", name, strings.Join(info.types, ", "))
+		}
+		validateValue(result, v, name, info)
+	}
+}
+
+// Validate a value according to the type of the key
+func validateValue(result *validationResult, v desktopValue, name string, info desktopKey) {
+	// Control characters must be written as escape sequences
+	if strings.IndexFunc(v.value, isControl) != -1 {
+		result.errorf(v.line, "the value of %s contains control characters", name)
+	}
+	elements, err := unescapeValue(v.value, info.list)
+	if err != nil {
+		result.errorf(v.line, "the value of %s is not properly escaped: %s", name, err)
+		return
+	}
+	for _, element := range elements {
+		switch info.valueType {
+		case valueBoolean:
+			if element != "true" && element != "false" {
+				result.errorf(v.line, "the value of %s must be true or false, not %q", name, element)
+			}
+		case valueNumeric:
+			if _, err := strconv.ParseFloat(element, 64); err != nil {
+				result.errorf(v.line, "the value of %s must be numeric, not %q", name, element)
+			}
+		case valueString:
+			for _, r := range element {
+				if r >= utf8.RuneSelf {
+					result.errorf(v.line, "the value of %s may only contain ASCII characters", name)
+					break
+				}
+			}
+		}
+	}
+}
+
+// Validate the Categories key
+func validateCategories(result *validationResult, v desktopValue) {
+	categories, err := unescapeValue(v.value, true)
+	if err != nil {
+		return
+	}
+	hasMain := false
+	for i, category := range categories {
+		switch {
+		case category == "":
+			result.warnf(v.line, "Categories contains an empty category")
+		case category == "Application":
+			result.warnf(v.line, "the category Application is not registered and should not be used")
+		case containsString(categories[:i], category):
+			result.warnf(v.line, "the category %s is given more than once", category)
+		case strings.HasPrefix(category, "X-"):
+		case !registeredCategory(category):
+			result.errorf(v.line, "the category %q is not registered, categories extending the format should start with X-", category)
+		case missingRelatedCategories(category, categories) != nil:
+			result.warnf(v.line, "the category %s should be used together with %s", category, describeRelatedCategories(category))
+		}
+		if mainCategories[category] {
+			hasMain = true
+		}
+	}
+	if !hasMain {
+		result.warnf(v.line, "Categories should contain at least one of the registered main categories")
+	}
+}
+
+// Validate the TryExec key, which is the path to a program, without arguments or field codes
+func validateTryExec(result *validationResult, v desktopValue) {
+	unescaped, err := unescapeValue(v.value, false)
+	if err != nil {
+		// Already reported for the value type
+		return
+	}
+	program := unescaped[0]
+	for i := 0; i+1 < len(program); i++ {
+		if c := program[i+1]; program[i] == '%' && c != '%' && strings.IndexByte(execFieldCodes+deprecatedExecFieldCodes, c) != -1 {
+			result.errorf(v.line, "TryExec can not contain the field code %%%c, it is only the path to a program", c)
+			return
+		}
+	}
+	// Absolute paths may contain spaces, but not arguments like --foo
+	words := strings.Fields(program)
+	for _, word := range words[min(1, len(words)):] {
+		if !filepath.IsAbs(program) || strings.HasPrefix(word, "-") {
+			result.errorf(v.line, "TryExec can not contain arguments, it is only the path to a program")
+			return
+		}
+	}
+}
+
+// Validate the Exec key, including the quoting and the field codes
+func validateExec(result *validationResult, v desktopValue) {
+	unescaped, err := unescapeValue(v.value, false)
+	if err != nil {
+		return
+	}
+	args, err := splitExec(unescaped[0])
+	if err != nil {
+		result.errorf(v.line, "invalid Exec value: %s", err)
+		return
+	}
+	problems, deprecated := checkFieldCodes(args)
+	for _, message := range deprecated {
+		result.warnf(v.line, "%s", message)
+	}
+	for _, message := range problems {
+		result.errorf(v.line, "%s", message)
+	}
+}
+
+// Sort problems by line number
+type byLine []ValidationProblem
+
+func (p byLine) Len() int           { return len(p) }
+func (p byLine) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
+func (p byLine) Less(i, j int) bool { return p[i].Line < p[j].Line }
+
+// Is the rune a control character, other than tab?
+func isControl(r rune) bool {
+	return (r < 0x20 && r != '\t') || r == 0x7f
+}
+
+// Does a list of strings contain the given string?
+func containsString(list []string, s string) bool {
+	for _, e := range list {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate the contents of a .desktop file, according to the Desktop Entry Specification.
+// Returns the problems that are found, sorted by line number.
+func Validate(contents []byte) []ValidationProblem {
+	return validateDesktopContents(string(contents)).problems
+}
+
+// Check if any of the problems is an error, not counting warnings
+func HasErrors(problems []ValidationProblem) bool {
+	result := validationResult{problems}
+	return result.hasErrors()
+}
@@ -0,0 +1,32 @@
+package gendesk
+
+import (
+	"strings"
+	"testing"
+)
+
+// TryExec is only the path to a program, without arguments or field codes
+func TestValidateTryExec(t *testing.T) {
+	tests := []struct {
+		tryExec string
+		problem string // a part of the expected error, or "" for none
+	}{
+		{"mywm", ""},
+		{"/usr/bin/mywm", ""},
+		{"/opt/My WM/bin/mywm", ""},
+		{"mywm --session %F", "field code %F"},
+		{"mywm --session", "arguments"},
+		{"/usr/bin/mywm --session", "arguments"},
+		{"mywm session", "arguments"},
+	}
+	for _, test := range tests {
+		contents := "[Desktop Entry]\nType=Application\nName=My WM\nExec=mywm\nTryExec=" + test.tryExec + "\n"
+		problems := Validate([]byte(contents))
+		switch {
+		case test.problem == "" && len(problems) > 0:
+			t.Errorf("TryExec=%s: unexpected problem: %s", test.tryExec, problems[0].Message)
+		case test.problem != "" && (len(problems) != 1 || !strings.Contains(problems[0].Message, test.problem)):
+			t.Errorf("TryExec=%s: expected a problem with %q, got %v", test.tryExec, test.problem, problems)
+		}
+	}
+}